@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"log"
+	"strings"
 )
 
 type Config struct {
@@ -11,6 +12,8 @@ type Config struct {
 	port           string
 	allowedOrigins string
 	noAudit        bool
+	adminToken     string
+	conversion     []string
 }
 
 func (c *Config) DataBridgeURL() string {
@@ -33,6 +36,15 @@ func (c *Config) HasAudit() bool {
 	return !c.noAudit
 }
 
+func (c *Config) AdminToken() string {
+	return c.adminToken
+}
+
+// ConversionSymbols lists the symbols which may be used to convert quote currencies, any symbol when empty
+func (c *Config) ConversionSymbols() []string {
+	return c.conversion
+}
+
 var serviceConfig = &Config{
 	dataBridgeURL:  "http://localhost:9701",
 	dataDir:        "",
@@ -51,6 +63,8 @@ func LoadConfig() {
 	confPort := flag.String("port", "9702", "port from which to run the service")
 	confAllowedOrigins := flag.String("origins", "*", "cors origins")
 	confNoAudit := flag.Bool("no-audit", false, "disables audit on startup")
+	confAdminToken := flag.String("admin-token", "", "bearer token for the admin api, disabled when empty")
+	confConversion := flag.String("conversion-symbols", "", "comma separated symbols used for quote conversion, all symbols when empty")
 	flag.Parse()
 
 	// Check validity
@@ -64,4 +78,11 @@ func LoadConfig() {
 	serviceConfig.port = *confPort
 	serviceConfig.allowedOrigins = *confAllowedOrigins
 	serviceConfig.noAudit = *confNoAudit
+	serviceConfig.adminToken = *confAdminToken
+	serviceConfig.conversion = make([]string, 0)
+	for _, symbol := range strings.Split(*confConversion, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			serviceConfig.conversion = append(serviceConfig.conversion, symbol)
+		}
+	}
 }
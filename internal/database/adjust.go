@@ -0,0 +1,185 @@
+package database
+
+import (
+	"fmt"
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Adjustment selects how corporate actions are applied to historical candles
+type Adjustment string
+
+const (
+	AdjustNone        = Adjustment("none")
+	AdjustSplits      = Adjustment("splits")
+	AdjustTotalReturn = Adjustment("total-return")
+)
+
+// Adjustments lists every supported adjustment mode
+var Adjustments = []Adjustment{AdjustNone, AdjustSplits, AdjustTotalReturn}
+
+// dividendLookBack limits how many blocks are searched for the close preceding an ex-dividend date
+const dividendLookBack = 3
+
+// dividendFactors memoizes the back-adjustment factor of each dividend, keyed by symbol, resolution and action
+var dividendFactors = make(map[string]float64)
+var dividendFactorsLock = sync.Mutex{}
+
+// ParseAdjustment reads an adjustment mode, an empty value selects the split-adjusted default
+func ParseAdjustment(s string) (Adjustment, bool) {
+	if s == "" {
+		return AdjustSplits, true
+	}
+	for _, adjust := range Adjustments {
+		if string(adjust) == s {
+			return adjust, true
+		}
+	}
+	return "", false
+}
+
+// applySplits scales the candles before every split onto the price level after it, volumes are scaled inversely
+func applySplits(data *candlestick.CandleSet, splits []candlestick.AssetSplit) {
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		for j := len(splits) - 1; j >= 0; j-- {
+			split := splits[j]
+			if c.Time >= split.Time {
+				break
+			}
+			c.Open /= split.Ratio
+			c.High /= split.Ratio
+			c.Low /= split.Ratio
+			c.Close /= split.Ratio
+			c.Volume *= split.Ratio
+			c.TakerVolume *= split.Ratio
+		}
+	}
+}
+
+// dividendFactor returns the factor applied to prices before an ex-dividend date, based on the last raw close before it
+func dividendFactor(symbol string, resolution int64, action store.Action) (float64, error) {
+
+	key := fmt.Sprintf("%s|%d|%s|%d", symbol, resolution, action.ID, action.Time)
+	dividendFactorsLock.Lock()
+	factor, ok := dividendFactors[key]
+	dividendFactorsLock.Unlock()
+	if ok {
+		return factor, nil
+	}
+
+	first := candlestick.UnixToBlock(action.Time-resolution, resolution)
+	for block := first; block > first-dividendLookBack; block-- {
+		s, err := primitiveSet(symbol, block, resolution, AdjustNone)
+		if err != nil {
+			return 1, err
+		}
+		if s == nil {
+			continue
+		}
+		for i := len(s.Candles) - 1; i >= 0; i-- {
+			c := &s.Candles[i]
+			if c.Missing || c.Time >= action.Time {
+				continue
+			}
+			if c.Close <= action.Amount {
+				log.Printf("ignoring dividend %s of %s exceeding the previous close\n", action.ID, symbol)
+				factor = 1
+			} else {
+				factor = 1 - action.Amount/c.Close
+			}
+			dividendFactorsLock.Lock()
+			dividendFactors[key] = factor
+			dividendFactorsLock.Unlock()
+			return factor, nil
+		}
+	}
+
+	// without a previous close the dividend can not be applied yet, data may still be downloaded
+	return 1, nil
+}
+
+// forgetDividendFactors drops the memoized factors of a symbol, its prices or actions have changed
+func forgetDividendFactors(symbol string) {
+	prefix := symbol + "|"
+	dividendFactorsLock.Lock()
+	for key := range dividendFactors {
+		if strings.HasPrefix(key, prefix) {
+			delete(dividendFactors, key)
+		}
+	}
+	dividendFactorsLock.Unlock()
+}
+
+// mergeSplits orders the splits of the bridge and the local actions store into a single list, a local split at the
+// time of a bridge split was recorded before the bridge reported it and is skipped so it is not applied twice
+func mergeSplits(bridge []candlestick.AssetSplit, actions []store.Action) []candlestick.AssetSplit {
+
+	splits := append(make([]candlestick.AssetSplit, 0, len(bridge)), bridge...)
+	reported := make(map[int64]bool, len(bridge))
+	for _, split := range bridge {
+		reported[split.Time] = true
+	}
+	for _, action := range actions {
+		if action.Type == store.ActionSplit && !reported[action.Time] {
+			splits = append(splits, candlestick.AssetSplit{Time: action.Time, Ratio: action.Ratio})
+		}
+	}
+	sort.SliceStable(splits, func(i, j int) bool {
+		return splits[i].Time < splits[j].Time
+	})
+	return splits
+}
+
+// adjustSet applies the corporate actions selected by the adjustment mode to a set loaded from disk
+func adjustSet(data *candlestick.CandleSet, adjust Adjustment) error {
+
+	if adjust == AdjustNone {
+		return nil
+	}
+
+	symbol := data.Symbol()
+	actions, err := store.SymbolActions(symbol)
+	if err != nil {
+		return err
+	}
+
+	var bridgeSplits []candlestick.AssetSplit
+	if info := store.AssetInfo(symbol); info != nil {
+		bridgeSplits = info.Splits
+	}
+	if splits := mergeSplits(bridgeSplits, actions); len(splits) > 0 {
+		applySplits(data, splits)
+	}
+
+	if adjust != AdjustTotalReturn {
+		return nil
+	}
+
+	// dividends are back-adjusted, every candle before an ex-date is scaled by the factor of that dividend
+	for _, action := range actions {
+		if action.Type != store.ActionDividend || action.Time <= data.UnixFirst() {
+			continue
+		}
+		factor, err := dividendFactor(symbol, data.Interval(), action)
+		if err != nil {
+			return err
+		}
+		for i := range data.Candles {
+			c := &data.Candles[i]
+			if c.Time >= action.Time {
+				break
+			}
+			c.Open *= factor
+			c.High *= factor
+			c.Low *= factor
+			c.Close *= factor
+		}
+	}
+
+	return nil
+}
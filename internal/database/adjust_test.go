@@ -0,0 +1,22 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"testing"
+)
+
+func TestMergeSplitsSkipsReportedSplits(t *testing.T) {
+
+	bridge := []candlestick.AssetSplit{{Time: 3000, Ratio: 2}}
+	actions := []store.Action{
+		{Type: store.ActionSplit, Time: 3000, Ratio: 2},
+		{Type: store.ActionSplit, Time: 1000, Ratio: 4},
+		{Type: store.ActionDividend, Time: 2000, Amount: 1},
+	}
+
+	splits := mergeSplits(bridge, actions)
+	if len(splits) != 2 || splits[0] != (candlestick.AssetSplit{Time: 1000, Ratio: 4}) || splits[1] != bridge[0] {
+		t.Errorf("unexpected splits %+v", splits)
+	}
+}
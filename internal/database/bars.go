@@ -0,0 +1,490 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"math"
+)
+
+// MaxBarCandles limits the number of primitive candles walked by a single bar request
+const MaxBarCandles = 100 * candlestick.CandleSetSize
+
+var ErrInvalidBarSize = errors.New("bar size must be positive")
+var ErrInvalidBarUnit = errors.New("unknown bar unit")
+
+// BarUnit is the activity measured by volume bars
+type BarUnit string
+
+const (
+	UnitVolume = BarUnit("volume")
+	UnitQuote  = BarUnit("quote")
+	UnitTrades = BarUnit("trades")
+)
+
+// Bar is a candle of variable length built from primitive candles, covering [Start, End)
+type Bar struct {
+	candlestick.Candle
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// BarCursor holds the state of a series where a request stopped, including the bar still open at that point, so a
+// later request continues the same series
+type BarCursor struct {
+	Time        int64   `json:"time"`
+	Point       int     `json:"point,omitempty"`
+	Price       float64 `json:"price"`
+	Top         float64 `json:"top,omitempty"`
+	Started     bool    `json:"started,omitempty"`
+	Size        float64 `json:"size"`
+	Unit        BarUnit `json:"unit,omitempty"`
+	Bar         *Bar    `json:"bar,omitempty"`
+	Accumulated float64 `json:"accumulated,omitempty"`
+}
+
+type BarSet struct {
+	Symbol     string     `json:"symbol"`
+	Type       string     `json:"type"`
+	Unit       BarUnit    `json:"unit,omitempty"`
+	Resolution int64      `json:"resolution"`
+	Size       float64    `json:"size"`
+	To         int64      `json:"to"`
+	Bars       []Bar      `json:"bars"`
+	Next       *BarCursor `json:"-"`
+}
+
+// smallestResolution returns the finest primitive resolution of the exchange of a symbol
+func smallestResolution(symbol string) (int64, error) {
+	if _, ok := store.ParseSynthetic(symbol); ok {
+		return 0, fmt.Errorf("%w: %s", store.ErrSyntheticSymbol, symbol)
+	}
+	info := store.AssetInfo(symbol)
+	if info == nil {
+		return 0, ErrSymbolNotFound
+	}
+	exchangeInfo := store.ExchangeInfo(info.Identifier.Exchange)
+	if exchangeInfo == nil || len(exchangeInfo.Resolution) == 0 {
+		return 0, ErrExchangeNotFound
+	}
+	smallest := exchangeInfo.Resolution[0]
+	for _, resolution := range exchangeInfo.Resolution {
+		if resolution < smallest {
+			smallest = resolution
+		}
+	}
+	return smallest, nil
+}
+
+// barWindow limits the end of a bar window to the number of primitive candles a request may walk
+func barWindow(from int64, to int64, resolution int64) int64 {
+	if to-from > MaxBarCandles*resolution {
+		return from + MaxBarCandles*resolution
+	}
+	return to
+}
+
+// candleWalker calls fn for every candle holding data in [from, to) in order until fn returns false
+type candleWalker func(from int64, to int64, fn func(c *candlestick.Candle) bool) error
+
+// primitiveWalker walks the primitive candles of a symbol
+func primitiveWalker(symbol string, resolution int64, adjust Adjustment) candleWalker {
+	return func(from int64, to int64, fn func(c *candlestick.Candle) bool) error {
+		return walkPrimitive(symbol, resolution, from, to, adjust, fn)
+	}
+}
+
+// openBar copies the bar left open by a cursor
+func openBar(cursor *BarCursor) *Bar {
+	if cursor == nil || cursor.Bar == nil {
+		return nil
+	}
+	b := *cursor.Bar
+	return &b
+}
+
+// walkPrimitive calls fn for every primitive candle holding data in [from, to) in order, crossing block boundaries,
+// until fn returns false
+func walkPrimitive(symbol string, resolution int64, from int64, to int64, adjust Adjustment, fn func(c *candlestick.Candle) bool) error {
+	for block := candlestick.UnixToBlock(from, resolution); candlestick.BlockToUnix(block, resolution) < to; block++ {
+		s, err := primitiveSet(symbol, block, resolution, adjust)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			continue
+		}
+		for i := range s.Candles {
+			c := &s.Candles[i]
+			if c.Missing || c.Time < from || c.Time >= to {
+				continue
+			}
+			if !fn(c) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// pricePath approximates the order in which a candle traded its prices, lows come first on rising candles
+func pricePath(c *candlestick.Candle) [4]float64 {
+	if c.Close >= c.Open {
+		return [4]float64{c.Open, c.Low, c.High, c.Close}
+	}
+	return [4]float64{c.Open, c.High, c.Low, c.Close}
+}
+
+// newBar opens a bar at a price
+func newBar(t int64, price float64) Bar {
+	return Bar{
+		Candle: candlestick.Candle{Time: t, Open: price, High: price, Low: price, Close: price},
+		Start:  t,
+	}
+}
+
+// addActivity accumulates the volume and trades of a candle into a bar
+func addActivity(b *Bar, c *candlestick.Candle) {
+	b.Volume += c.Volume
+	b.TakerVolume += c.TakerVolume
+	b.NumberOfTrades += c.NumberOfTrades
+}
+
+// AverageTrueRange computes the average true range over the period candles of an interval preceding t
+func AverageTrueRange(symbol string, t int64, interval int64, period int64, adjust Adjustment) (float64, error) {
+
+	data, err := FetchCandleRange(symbol, t-(period+1)*interval, t, interval, adjust, true)
+	if err != nil {
+		return 0, err
+	}
+
+	ranges := make([]float64, 0, period)
+	var prev *candlestick.Candle
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		if c.Missing {
+			continue
+		}
+		tr := c.High - c.Low
+		if prev != nil {
+			tr = math.Max(tr, math.Max(math.Abs(c.High-prev.Close), math.Abs(c.Low-prev.Close)))
+		}
+		ranges = append(ranges, tr)
+		prev = c
+	}
+	if len(ranges) == 0 {
+		return 0, ErrBlockNotDownloaded
+	}
+	if int64(len(ranges)) > period {
+		ranges = ranges[int64(len(ranges))-period:]
+	}
+
+	sum := 0.0
+	for _, tr := range ranges {
+		sum += tr
+	}
+	return sum / float64(len(ranges)), nil
+}
+
+// FetchRenkoBars builds close-based renko bricks of a fixed size in [from, to), a brick forms when the close moves a
+// full size beyond the top or bottom of the last brick, so reversals require twice the size
+func FetchRenkoBars(symbol string, from int64, to int64, size float64, cursor *BarCursor, limit int, adjust Adjustment) (*BarSet, error) {
+
+	if cursor != nil {
+		from, size = cursor.Time, cursor.Size
+	}
+	if size <= 0 {
+		return nil, ErrInvalidBarSize
+	}
+
+	resolution, err := smallestResolution(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BarSet{
+		Symbol:     symbol,
+		Type:       "renko",
+		Resolution: resolution,
+		Size:       size,
+		To:         barWindow(from, to, resolution),
+		Bars:       make([]Bar, 0),
+	}
+	if err = renkoBars(result, primitiveWalker(symbol, resolution, adjust), from, cursor, limit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// renkoBars appends the bricks formed by the candles of [from, result.To) to result and sets the cursor continuing
+// the series, either inside the candle which would exceed the limit or at the end of the window
+func renkoBars(result *BarSet, walk candleWalker, from int64, cursor *BarCursor, limit int) error {
+
+	size, resolution := result.Size, result.Resolution
+
+	// the last brick spans [bottom, top], the first candle anchors the series unless continuing
+	started := cursor != nil && cursor.Started
+	top, bottom := 0.0, 0.0
+	if started {
+		top, bottom = cursor.Top, cursor.Price
+	}
+	pending := openBar(cursor)
+
+	// a candle cut off by the limit has its activity attributed already
+	resume := int64(math.MinInt64)
+	if cursor != nil && cursor.Point > 0 {
+		resume = cursor.Time
+	}
+
+	err := walk(from, result.To, func(c *candlestick.Candle) bool {
+		if !started {
+			top, bottom, started = c.Close, c.Close, true
+		}
+		emitted := c.Time == resume
+		if pending == nil {
+			b := newBar(c.Time, c.Close)
+			pending = &b
+		}
+		if !emitted {
+			addActivity(pending, c)
+		}
+
+		for c.Close >= top+size || c.Close <= bottom-size {
+			if len(result.Bars) >= limit {
+				result.Next = &BarCursor{Time: c.Time, Point: 1, Price: bottom, Top: top, Started: true, Size: size, Bar: pending}
+				return false
+			}
+			brick := *pending
+			if c.Close >= top+size {
+				brick.Open, brick.Close = top, top+size
+				bottom, top = top, top+size
+			} else {
+				brick.Open, brick.Close = bottom, bottom-size
+				top, bottom = bottom, bottom-size
+			}
+			brick.High = math.Max(brick.Open, brick.Close)
+			brick.Low = math.Min(brick.Open, brick.Close)
+			brick.End = c.Time + resolution
+			result.Bars = append(result.Bars, brick)
+
+			// activity is attributed to the first brick of a candle
+			next := newBar(c.Time, brick.Close)
+			pending = &next
+			emitted = true
+		}
+		if emitted {
+			pending = nil
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Next == nil {
+		result.Next = &BarCursor{Time: result.To, Price: bottom, Top: top, Started: started, Size: size, Bar: pending}
+	}
+	return nil
+}
+
+// FetchRangeBars builds bars in [from, to) which close as soon as their high and low are a full size apart, every
+// primitive candle is replayed as a price path through its open, high, low and close
+func FetchRangeBars(symbol string, from int64, to int64, size float64, cursor *BarCursor, limit int, adjust Adjustment) (*BarSet, error) {
+
+	if cursor != nil {
+		from, size = cursor.Time, cursor.Size
+	}
+	if size <= 0 {
+		return nil, ErrInvalidBarSize
+	}
+
+	resolution, err := smallestResolution(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BarSet{
+		Symbol:     symbol,
+		Type:       "range",
+		Resolution: resolution,
+		Size:       size,
+		To:         barWindow(from, to, resolution),
+		Bars:       make([]Bar, 0),
+	}
+	if err = rangeBars(result, primitiveWalker(symbol, resolution, adjust), from, cursor, limit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rangeBars appends the bars closed by the candles of [from, result.To) to result and sets the cursor continuing
+// the series, either at the price point which would exceed the limit or at the end of the window
+func rangeBars(result *BarSet, walk candleWalker, from int64, cursor *BarCursor, limit int) error {
+
+	size, resolution := result.Size, result.Resolution
+	bar := openBar(cursor)
+
+	// a continued series resumes at the price point where the limit was reached
+	resume, resumePoint := int64(math.MinInt64), 0
+	if cursor != nil {
+		resume, resumePoint = cursor.Time, cursor.Point
+	}
+
+	err := walk(from, result.To, func(c *candlestick.Candle) bool {
+
+		first := 0
+		if c.Time == resume {
+			first = resumePoint
+		}
+
+		path := pricePath(c)
+		for point := first; point < len(path); point++ {
+			p := path[point]
+			if bar == nil {
+				b := newBar(c.Time, p)
+				bar = &b
+			}
+			for p > bar.Low+size || p < bar.High-size {
+				if len(result.Bars) >= limit {
+					result.Next = &BarCursor{Time: c.Time, Point: point, Price: bar.Close, Size: size, Bar: bar}
+					return false
+				}
+				if p > bar.Low+size {
+					bar.High = bar.Low + size
+				} else {
+					bar.Low = bar.High - size
+				}
+				bar.Close = p
+				if bar.Close > bar.High {
+					bar.Close = bar.High
+				}
+				if bar.Close < bar.Low {
+					bar.Close = bar.Low
+				}
+				bar.End = c.Time + resolution
+				result.Bars = append(result.Bars, *bar)
+				next := newBar(c.Time, bar.Close)
+				bar = &next
+			}
+			bar.High = math.Max(bar.High, p)
+			bar.Low = math.Min(bar.Low, p)
+			bar.Close = p
+		}
+
+		// activity is attributed to the bar the candle closes in
+		addActivity(bar, c)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Next == nil {
+		result.Next = &BarCursor{Time: result.To, Size: size, Bar: bar}
+		if bar != nil {
+			result.Next.Price = bar.Close
+		}
+	}
+	return nil
+}
+
+// activity measures a candle in a unit, quote volume is approximated from the typical price as candles carry base
+// volume only
+func activity(c *candlestick.Candle, unit BarUnit) float64 {
+	switch unit {
+	case UnitQuote:
+		return c.Volume * (c.High + c.Low + c.Close) / 3
+	case UnitTrades:
+		return float64(c.NumberOfTrades)
+	default:
+		return c.Volume
+	}
+}
+
+// FetchVolumeBars builds bars in [from, to) which close once their activity in a unit reaches size, primitive
+// candles are not divided so a bar may overshoot its size
+func FetchVolumeBars(symbol string, from int64, to int64, unit BarUnit, size float64, cursor *BarCursor, limit int, adjust Adjustment) (*BarSet, error) {
+
+	if cursor != nil {
+		from, size, unit = cursor.Time, cursor.Size, cursor.Unit
+	}
+	switch unit {
+	case UnitVolume, UnitQuote, UnitTrades:
+	default:
+		return nil, ErrInvalidBarUnit
+	}
+	if size <= 0 {
+		return nil, ErrInvalidBarSize
+	}
+
+	resolution, err := smallestResolution(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BarSet{
+		Symbol:     symbol,
+		Type:       "volume",
+		Unit:       unit,
+		Resolution: resolution,
+		Size:       size,
+		To:         barWindow(from, to, resolution),
+		Bars:       make([]Bar, 0),
+	}
+	if err = volumeBars(result, primitiveWalker(symbol, resolution, adjust), from, cursor, limit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// volumeBars appends the bars closed by the candles of [from, result.To) to result and sets the cursor continuing
+// the series, either after the bar reaching the limit or at the end of the window
+func volumeBars(result *BarSet, walk candleWalker, from int64, cursor *BarCursor, limit int) error {
+
+	size, unit, resolution := result.Size, result.Unit, result.Resolution
+	bar := openBar(cursor)
+	accumulated := 0.0
+	if bar != nil {
+		accumulated = cursor.Accumulated
+	}
+
+	err := walk(from, result.To, func(c *candlestick.Candle) bool {
+		if bar == nil {
+			b := newBar(c.Time, c.Open)
+			b.High, b.Low = c.High, c.Low
+			bar = &b
+			accumulated = 0
+		}
+		bar.High = math.Max(bar.High, c.High)
+		bar.Low = math.Min(bar.Low, c.Low)
+		bar.Close = c.Close
+		addActivity(bar, c)
+
+		accumulated += activity(c, unit)
+		if accumulated < size {
+			return true
+		}
+
+		bar.End = c.Time + resolution
+		result.Bars = append(result.Bars, *bar)
+		bar = nil
+		if len(result.Bars) >= limit {
+			result.Next = &BarCursor{Time: c.Time + resolution, Size: size, Unit: unit}
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Next == nil {
+		result.Next = &BarCursor{Time: result.To, Size: size, Unit: unit, Bar: bar}
+		if bar != nil {
+			result.Next.Price = bar.Close
+			result.Next.Accumulated = accumulated
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"encoding/json"
+	"github.com/godoji/candlestick"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPricePath(t *testing.T) {
+
+	rising := candlestick.Candle{Open: 10, High: 14, Low: 9, Close: 12}
+	if path := pricePath(&rising); path != [4]float64{10, 9, 14, 12} {
+		t.Errorf("unexpected rising path %v", path)
+	}
+
+	falling := candlestick.Candle{Open: 12, High: 14, Low: 9, Close: 10}
+	if path := pricePath(&falling); path != [4]float64{12, 14, 9, 10} {
+		t.Errorf("unexpected falling path %v", path)
+	}
+}
+
+func TestBarWindow(t *testing.T) {
+	if to := barWindow(0, 3600, 60); to != 3600 {
+		t.Errorf("small window was limited to %d", to)
+	}
+	if to := barWindow(60, 60+2*MaxBarCandles*60, 60); to != 60+MaxBarCandles*60 {
+		t.Errorf("large window was limited to %d", to)
+	}
+}
+
+func TestActivity(t *testing.T) {
+	c := candlestick.Candle{High: 12, Low: 9, Close: 12, Volume: 4, NumberOfTrades: 7}
+	if v := activity(&c, UnitVolume); v != 4 {
+		t.Errorf("unexpected volume %f", v)
+	}
+	if v := activity(&c, UnitQuote); v != 44 {
+		t.Errorf("unexpected quote volume %f", v)
+	}
+	if v := activity(&c, UnitTrades); v != 7 {
+		t.Errorf("unexpected trades %f", v)
+	}
+}
+
+// testBarCandles returns a fixed series of minute candles drifting in waves with a few gaps spanning several sizes
+func testBarCandles() []candlestick.Candle {
+	candles := make([]candlestick.Candle, 0, 400)
+	price := 100.0
+	for i := 0; i < 400; i++ {
+		open := price
+		price += 3 * math.Sin(float64(i)/9)
+		if i%97 == 50 {
+			price += 25
+		}
+		candles = append(candles, candlestick.Candle{
+			Open:           open,
+			High:           math.Max(open, price) + 0.5,
+			Low:            math.Min(open, price) - 0.5,
+			Close:          price,
+			Volume:         float64(i%5 + 1),
+			NumberOfTrades: int64(i%3 + 1),
+			Time:           int64(i) * 60,
+			Missing:        i%61 == 30,
+		})
+	}
+	return candles
+}
+
+func sliceWalker(candles []candlestick.Candle) candleWalker {
+	return func(from int64, to int64, fn func(c *candlestick.Candle) bool) error {
+		for i := range candles {
+			c := &candles[i]
+			if c.Missing || c.Time < from || c.Time >= to {
+				continue
+			}
+			if !fn(c) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+type barBuilder func(result *BarSet, walk candleWalker, from int64, cursor *BarCursor, limit int) error
+
+// pagedBars builds the bars of all candles in windows of the given length, continuing every request from the
+// encoded cursor of the previous one
+func pagedBars(t *testing.T, build barBuilder, candles []candlestick.Candle, size float64, limit int, window int64) []Bar {
+	walk := sliceWalker(candles)
+	end := candles[len(candles)-1].Time + 60
+	bars := make([]Bar, 0)
+	from := candles[0].Time
+	var cursor *BarCursor
+	for requests := 0; from < end; requests++ {
+		if requests > 10000 {
+			t.Fatalf("paging does not progress at %d", from)
+		}
+		to := from + window
+		if to > end {
+			to = end
+		}
+		result := &BarSet{Resolution: 60, Size: size, Unit: UnitVolume, To: to, Bars: make([]Bar, 0)}
+		if err := build(result, walk, from, cursor, limit); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Bars) > limit {
+			t.Fatalf("got %d bars with limit %d", len(result.Bars), limit)
+		}
+		bars = append(bars, result.Bars...)
+		if result.Next == nil {
+			t.Fatal("no cursor returned")
+		}
+		payload, _ := json.Marshal(result.Next)
+		cursor = new(BarCursor)
+		if err := json.Unmarshal(payload, cursor); err != nil {
+			t.Fatal(err)
+		}
+		from = cursor.Time
+	}
+	return bars
+}
+
+func TestBarsResumeFromCursor(t *testing.T) {
+
+	candles := testBarCandles()
+	builders := []struct {
+		name  string
+		build barBuilder
+		size  float64
+	}{
+		{"renko", renkoBars, 4},
+		{"range", rangeBars, 6},
+		{"volume", volumeBars, 12},
+	}
+
+	for _, b := range builders {
+		whole := pagedBars(t, b.build, candles, b.size, 1<<20, 1<<20)
+		if len(whole) < 10 {
+			t.Fatalf("%s: expected a series of bars, got %d", b.name, len(whole))
+		}
+		for _, limit := range []int{1, 2, 7} {
+			for _, window := range []int64{60, 7 * 60, 1 << 20} {
+				paged := pagedBars(t, b.build, candles, b.size, limit, window)
+				if !reflect.DeepEqual(whole, paged) {
+					t.Errorf("%s: paging with limit %d and window %d differs", b.name, limit, window)
+				}
+			}
+		}
+	}
+}
+
+func TestRenkoBars(t *testing.T) {
+
+	candles := []candlestick.Candle{
+		{Open: 100, High: 100, Low: 100, Close: 100, Volume: 1, Time: 0},
+		{Open: 100, High: 103, Low: 100, Close: 103, Volume: 2, Time: 60},
+		{Open: 103, High: 108, Low: 103, Close: 107, Volume: 3, Time: 120},
+		{Open: 107, High: 107, Low: 102, Close: 102, Volume: 4, Time: 180},
+	}
+	result := &BarSet{Resolution: 60, Size: 2, To: 240, Bars: make([]Bar, 0)}
+	if err := renkoBars(result, sliceWalker(candles), 0, nil, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// one brick from the first gain, two on the next candle and one down after a reversal of twice the size
+	closes := []float64{102, 104, 106, 102}
+	if len(result.Bars) != len(closes) {
+		t.Fatalf("expected %d bricks, got %d", len(closes), len(result.Bars))
+	}
+	for i, c := range closes {
+		if result.Bars[i].Close != c {
+			t.Errorf("brick %d closes at %f, expected %f", i, result.Bars[i].Close, c)
+		}
+	}
+	if result.Bars[0].Volume != 3 || result.Bars[1].Volume != 3 || result.Bars[2].Volume != 0 {
+		t.Errorf("activity not attributed to the first brick of a candle")
+	}
+
+	// the limit stops inside a candle and the cursor continues with its remaining brick
+	limited := &BarSet{Resolution: 60, Size: 2, To: 240, Bars: make([]Bar, 0)}
+	if err := renkoBars(limited, sliceWalker(candles), 0, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(limited.Bars) != 2 || limited.Next.Time != 120 || limited.Next.Point != 1 {
+		t.Fatalf("unexpected limited series %d bricks, cursor %+v", len(limited.Bars), limited.Next)
+	}
+}
+
+func TestBarsCursorWithoutProgress(t *testing.T) {
+
+	candles := []candlestick.Candle{
+		{Open: 100, High: 101, Low: 99, Close: 100.5, Volume: 1, Time: 0},
+		{Open: 100.5, High: 101, Low: 100, Close: 100, Volume: 1, Time: 60},
+	}
+	for name, build := range map[string]barBuilder{"renko": renkoBars, "range": rangeBars, "volume": volumeBars} {
+		result := &BarSet{Resolution: 60, Size: 100, Unit: UnitVolume, To: 120, Bars: make([]Bar, 0)}
+		if err := build(result, sliceWalker(candles), 0, nil, 10); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Bars) != 0 {
+			t.Fatalf("%s: unexpected bars", name)
+		}
+		if result.Next == nil || result.Next.Time != 120 || result.Next.Bar == nil || result.Next.Bar.Volume != 2 {
+			t.Errorf("%s: expected cursor at the window end carrying the open bar, got %+v", name, result.Next)
+		}
+	}
+}
@@ -3,24 +3,178 @@ package database
 import (
 	"fmt"
 	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/godoji/candlestick"
+	"kio/internal/metrics"
+	"kio/internal/store"
 	"log"
+	"sync"
+	"time"
 )
 
 var candleSetCache *ristretto.Cache
 var candleSetCost = int64(360512)
 
+// cacheEntry describes a cached candle set so it can be purged without keeping the set itself alive
+type cacheEntry struct {
+	key      string
+	conflict uint64
+	symbol   string
+	interval int64
+	block    int64
+}
+
+// cacheIndex tracks the entries admitted to the candle set cache by key hash, ristretto only keeps key hashes
+var cacheIndex = make(map[uint64]cacheEntry)
+var cacheIndexLock = sync.Mutex{}
+
+var purgeListeners = make([]func(symbol string), 0)
+var purgeListenersLock = sync.Mutex{}
+
 func init() {
 	var err error
 	candleSetCache, err = ristretto.NewCache(&ristretto.Config{
 		NumCounters: 1 << 12,                   // 8k items
 		MaxCost:     (1 << 12) * candleSetCost, // 3GB
 		BufferItems: 64,
+		Metrics:     true,
+		OnEvict:     dropIndexedItem,
+		OnReject:    dropIndexedItem,
 	})
 	if err != nil {
 		log.Fatal(err)
 	}
+	metrics.RegisterCache("candle_sets", candleSetCache.Metrics)
+}
+
+func getCacheKey(symbol string, block int64, interval int64, adjust Adjustment, kind CandleType) string {
+	return fmt.Sprintf("%s_%d_%d_%s_%s", symbol, block, interval, adjust, kind)
+}
+
+// cacheSet stores a candle set and records it in the index when the cache accepted it, a ttl of zero keeps the set
+// until it is evicted. The index lock is held across the set so an eviction or rejection can not be handled before
+// the entry was indexed, ristretto reports those from its own goroutine.
+func cacheSet(data *candlestick.CandleSet, adjust Adjustment, kind CandleType, ttl time.Duration) {
+	key := getCacheKey(data.Symbol(), data.BlockNumber(), data.Interval(), adjust, kind)
+	cacheIndexLock.Lock()
+	defer cacheIndexLock.Unlock()
+	if !candleSetCache.SetWithTTL(key, data, candleSetCost, ttl) {
+		return
+	}
+	hash, conflict := z.KeyToHash(key)
+	cacheIndex[hash] = cacheEntry{
+		key:      key,
+		conflict: conflict,
+		symbol:   data.Symbol(),
+		interval: data.Interval(),
+		block:    data.BlockNumber(),
+	}
+}
+
+// dropIndexedItem removes evicted or rejected sets from the index
+func dropIndexedItem(item *ristretto.Item) {
+	cacheIndexLock.Lock()
+	if entry, ok := cacheIndex[item.Key]; ok && entry.conflict == item.Conflict {
+		delete(cacheIndex, item.Key)
+	}
+	cacheIndexLock.Unlock()
+}
+
+type CacheStats struct {
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hitRatio"`
+	KeysAdded   uint64  `json:"keysAdded"`
+	KeysEvicted uint64  `json:"keysEvicted"`
+	CostAdded   uint64  `json:"costAdded"`
+	CostEvicted uint64  `json:"costEvicted"`
+	Keys        int     `json:"keys"`
+	MaxCost     int64   `json:"maxCost"`
+}
+
+// CandleCacheStats reports the metrics of the candle set cache
+func CandleCacheStats() CacheStats {
+	m := candleSetCache.Metrics
+	cacheIndexLock.Lock()
+	keys := len(cacheIndex)
+	cacheIndexLock.Unlock()
+	return CacheStats{
+		Hits:        m.Hits(),
+		Misses:      m.Misses(),
+		HitRatio:    m.Ratio(),
+		KeysAdded:   m.KeysAdded(),
+		KeysEvicted: m.KeysEvicted(),
+		CostAdded:   m.CostAdded(),
+		CostEvicted: m.CostEvicted(),
+		Keys:        keys,
+		MaxCost:     candleSetCache.MaxCost(),
+	}
 }
 
-func getCacheKey(symbol string, block int64, interval int64) string {
-	return fmt.Sprintf("%s_%d_%d", symbol, block, interval)
+// OnCachePurge registers a listener which is called after cached sets of a symbol were purged
+func OnCachePurge(listener func(symbol string)) {
+	purgeListenersLock.Lock()
+	purgeListeners = append(purgeListeners, listener)
+	purgeListenersLock.Unlock()
+}
+
+// purgeCache removes all cached sets of a symbol matching the filter and returns how many were removed, sets of
+// synthetic symbols built from the symbol are removed along with it
+func purgeCache(symbol string, match func(entry cacheEntry) bool) int {
+
+	// deletions wait for the cache to process its buffer, whose evictions take the index lock
+	cacheIndexLock.Lock()
+	keys := make([]string, 0)
+	for hash, entry := range cacheIndex {
+		if !store.IsSymbolOrLeg(entry.symbol, symbol) || !match(entry) {
+			continue
+		}
+		keys = append(keys, entry.key)
+		delete(cacheIndex, hash)
+	}
+	cacheIndexLock.Unlock()
+	for _, key := range keys {
+		candleSetCache.Del(key)
+	}
+	removed := len(keys)
+	forgetDividendFactors(symbol)
+
+	// layers caching representations of these sets have to drop them as well
+	purgeListenersLock.Lock()
+	listeners := purgeListeners
+	purgeListenersLock.Unlock()
+	for _, listener := range listeners {
+		listener(symbol)
+	}
+
+	return removed
+}
+
+// PurgeSymbol removes all cached sets of a symbol
+func PurgeSymbol(symbol string) int {
+	return purgeCache(symbol, func(entry cacheEntry) bool {
+		return true
+	})
+}
+
+// PurgeInterval removes all cached sets of a symbol at an interval, including larger intervals derived from it
+func PurgeInterval(symbol string, interval int64) int {
+	return purgeCache(symbol, func(entry cacheEntry) bool {
+		return entry.interval >= interval
+	})
+}
+
+// PurgeBlocks removes the cached sets of the block range [fromBlock, toBlock] of a symbol, including every set of a
+// larger interval that overlaps the range in time since those are aggregated from the purged blocks
+func PurgeBlocks(symbol string, interval int64, fromBlock int64, toBlock int64) int {
+	start := candlestick.BlockToUnix(fromBlock, interval)
+	end := candlestick.BlockToUnix(toBlock+1, interval)
+	return purgeCache(symbol, func(entry cacheEntry) bool {
+		if entry.interval < interval {
+			return false
+		}
+		setStart := candlestick.BlockToUnix(entry.block, entry.interval)
+		setEnd := candlestick.BlockToUnix(entry.block+1, entry.interval)
+		return setStart < end && start < setEnd
+	})
 }
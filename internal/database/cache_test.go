@@ -0,0 +1,62 @@
+package database
+
+import (
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func TestCacheIndexFollowsCache(t *testing.T) {
+
+	data := &candlestick.CandleSet{
+		Candles: make([]candlestick.Candle, candlestick.CandleSetSize),
+		Meta: candlestick.DataSetMeta{
+			Symbol:   "TEST:SPOT:CACHEUSDT",
+			Interval: candlestick.Interval1m,
+			Block:    3,
+		},
+	}
+	key := getCacheKey(data.Symbol(), data.BlockNumber(), data.Interval(), AdjustNone, TypeCandles)
+	hash, conflict := z.KeyToHash(key)
+
+	cacheSet(data, AdjustNone, TypeCandles, 0)
+	candleSetCache.Wait()
+	if _, ok := candleSetCache.Get(key); !ok {
+		t.Skip("set was not admitted by the cache")
+	}
+
+	cacheIndexLock.Lock()
+	entry, ok := cacheIndex[hash]
+	cacheIndexLock.Unlock()
+	if !ok || entry.key != key || entry.block != 3 || entry.interval != candlestick.Interval1m {
+		t.Fatalf("unexpected index entry %+v", entry)
+	}
+
+	// evictions of other keys sharing the hash leave the entry alone
+	dropIndexedItem(&ristretto.Item{Key: hash, Conflict: conflict + 1})
+	cacheIndexLock.Lock()
+	_, ok = cacheIndex[hash]
+	cacheIndexLock.Unlock()
+	if !ok {
+		t.Fatal("entry dropped by conflicting eviction")
+	}
+
+	if n := PurgeBlocks(data.Symbol(), candlestick.Interval1m, 3, 3); n != 1 {
+		t.Fatalf("expected one purged set, got %d", n)
+	}
+	if _, ok = candleSetCache.Get(key); ok {
+		t.Fatal("purged set is still cached")
+	}
+
+	cacheSet(data, AdjustNone, TypeCandles, 0)
+	candleSetCache.Wait()
+	dropIndexedItem(&ristretto.Item{Key: hash, Conflict: conflict})
+	cacheIndexLock.Lock()
+	_, ok = cacheIndex[hash]
+	cacheIndexLock.Unlock()
+	if ok {
+		t.Fatal("evicted set is still indexed")
+	}
+	candleSetCache.Del(key)
+}
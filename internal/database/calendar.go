@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"time"
+)
+
+type CalendarPeriod string
+
+const (
+	PeriodWeek    = CalendarPeriod("week")
+	PeriodMonth   = CalendarPeriod("month")
+	PeriodQuarter = CalendarPeriod("quarter")
+	PeriodYear    = CalendarPeriod("year")
+)
+
+var ErrInvalidPeriod = errors.New("invalid calendar period")
+
+// CalendarCandle is a candle of unequal length, covering [Start, End)
+type CalendarCandle struct {
+	candlestick.Candle
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+type CalendarSet struct {
+	Symbol   string           `json:"symbol"`
+	Period   CalendarPeriod   `json:"period"`
+	Complete bool             `json:"complete"`
+	Candles  []CalendarCandle `json:"candles"`
+}
+
+// CalendarPeriodStart returns the utc start of the calendar period containing t, weeks start on monday
+func CalendarPeriodStart(t time.Time, period CalendarPeriod) (time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)), nil
+	case PeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case PeriodQuarter:
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC), nil
+	case PeriodYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return t, ErrInvalidPeriod
+}
+
+// nextCalendarPeriod returns the start of the period following the period starting at start
+func nextCalendarPeriod(start time.Time, period CalendarPeriod) time.Time {
+	switch period {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	case PeriodQuarter:
+		return start.AddDate(0, 3, 0)
+	}
+	return start.AddDate(1, 0, 0)
+}
+
+// FetchCalendarCandles aggregates the daily candles of every calendar period overlapping [from, to)
+func FetchCalendarCandles(symbol string, period CalendarPeriod, from int64, to int64, adjust Adjustment, useCache bool) (*CalendarSet, error) {
+
+	first, err := CalendarPeriodStart(time.Unix(from, 0), period)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the boundaries of all periods in the window
+	bounds := []time.Time{first}
+	for bounds[len(bounds)-1].Unix() < to {
+		bounds = append(bounds, nextCalendarPeriod(bounds[len(bounds)-1], period))
+	}
+	if len(bounds) < 2 {
+		return nil, ErrBlockNotDownloaded
+	}
+
+	days, err := FetchCandleRange(symbol, first.Unix(), bounds[len(bounds)-1].Unix(), candlestick.Interval1d, adjust, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CalendarSet{
+		Symbol:   symbol,
+		Period:   period,
+		Complete: days.IsComplete() && bounds[len(bounds)-1].Unix() <= time.Now().UTC().Unix(),
+		Candles:  make([]CalendarCandle, len(bounds)-1),
+	}
+	for i := range result.Candles {
+		result.Candles[i] = CalendarCandle{
+			Candle: candlestick.Candle{
+				Time:    bounds[i].Unix(),
+				Missing: true,
+			},
+			Start: bounds[i].Unix(),
+			End:   bounds[i+1].Unix(),
+		}
+	}
+
+	// days are in order, so the period index only moves forward
+	index := 0
+	for i := range days.Candles {
+		c := &days.Candles[i]
+		for index < len(result.Candles) && c.Time >= result.Candles[index].End {
+			index++
+		}
+		if index == len(result.Candles) {
+			break
+		}
+		mergeCandles(c, &result.Candles[index].Candle)
+	}
+
+	return result, nil
+}
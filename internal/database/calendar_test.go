@@ -0,0 +1,36 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarPeriodStart(t *testing.T) {
+
+	// sunday evening, the last day of an iso week, a month and a quarter
+	ts := time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC)
+
+	cases := map[CalendarPeriod]time.Time{
+		PeriodWeek:    time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC),
+		PeriodMonth:   time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+		PeriodQuarter: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC),
+		PeriodYear:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for period, expected := range cases {
+		start, err := CalendarPeriodStart(ts, period)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !start.Equal(expected) {
+			t.Errorf("%s: expected %s but got %s", period, expected, start)
+		}
+		if next := nextCalendarPeriod(start, period); !next.After(ts) {
+			t.Errorf("%s: next period %s does not follow %s", period, next, ts)
+		}
+	}
+
+	if _, err := CalendarPeriodStart(ts, "fortnight"); err != ErrInvalidPeriod {
+		t.Errorf("expected invalid period error but got %v", err)
+	}
+}
@@ -10,22 +10,40 @@ import (
 	"time"
 )
 
-func FetchCandles(symbol string, block int64, interval int64, useCache bool) (*candlestick.CandleSet, error) {
+var (
+	ErrSymbolNotFound     = errors.New("symbol not found")
+	ErrExchangeNotFound   = errors.New("exchange not found")
+	ErrInvalidInterval    = errors.New("invalid interval requested")
+	ErrBeforeOnBoardDate  = errors.New("requested data lies before the on board date")
+	ErrBlockNotDownloaded = errors.New("data does not exist or hasn't been downloaded yet")
+)
+
+// isAbsent reports whether an error only signals that no data is available for a block
+func isAbsent(err error) bool {
+	return errors.Is(err, ErrBlockNotDownloaded) || errors.Is(err, ErrBeforeOnBoardDate)
+}
+
+func FetchCandles(symbol string, block int64, interval int64, adjust Adjustment, useCache bool) (*candlestick.CandleSet, error) {
 
 	// Retrieve symbol info
 	symbolInfo := store.AssetInfo(symbol)
 	if symbolInfo == nil {
-		return nil, errors.New("symbol not found")
+		return nil, ErrSymbolNotFound
+	}
+
+	// Synthetic symbols are combined from their legs at the requested interval
+	if synthetic, ok := store.ParseSynthetic(symbol); ok {
+		return fetchSyntheticCandles(symbol, synthetic, block, interval, adjust, useCache)
 	}
 
 	// Retrieve exchange info to get primitive intervals
 	exchangeInfo := store.ExchangeInfo(symbolInfo.Identifier.Exchange)
 	if exchangeInfo == nil {
-		return nil, errors.New("exchange not found")
+		return nil, ErrExchangeNotFound
 	}
 
 	// Check cache for any existing versions
-	key := getCacheKey(symbol, block, interval)
+	key := getCacheKey(symbol, block, interval, adjust, TypeCandles)
 	if useCache {
 		v, ok := candleSetCache.Get(key)
 		if ok {
@@ -37,7 +55,14 @@ func FetchCandles(symbol string, block int64, interval int64, useCache bool) (*c
 	smallestResolution := int64(-1)
 	for _, candidate := range exchangeInfo.Resolution {
 		if candidate == interval {
-			return primitiveSet(symbol, block, interval)
+			s, err := primitiveSet(symbol, block, interval, adjust)
+			if err == nil && s == nil {
+				if candlestick.BlockToUnix(block+1, interval)-interval < symbolInfo.OnBoardDate {
+					return nil, ErrBeforeOnBoardDate
+				}
+				return nil, ErrBlockNotDownloaded
+			}
+			return s, err
 		}
 		if smallestResolution == -1 || candidate < smallestResolution {
 			smallestResolution = candidate
@@ -45,7 +70,14 @@ func FetchCandles(symbol string, block int64, interval int64, useCache bool) (*c
 	}
 
 	if interval < smallestResolution {
-		return nil, nil
+		return nil, fmt.Errorf("%w %d", ErrInvalidInterval, interval)
+	}
+
+	// Retrieve sub interval from which to create parent set
+	subInterval, err := SubInterval(interval, exchangeInfo.Resolution)
+	if err != nil {
+		log.Printf("invalid interval requested %d\n", interval)
+		return nil, err
 	}
 
 	// Check onboard date
@@ -57,16 +89,9 @@ func FetchCandles(symbol string, block int64, interval int64, useCache bool) (*c
 	// Return nothing if the data requested is before on board date
 	lastBlockCandle := candlestick.BlockToUnix(block+1, interval) - interval
 	if lastBlockCandle < onBoardDate {
-		return nil, nil
+		return nil, ErrBeforeOnBoardDate
 	}
 
-	// Retrieve sub interval from which to create parent set
-	subInterval, ok := candlestick.IntervalMap[interval]
-	if !ok {
-		errMsg := fmt.Sprintf("invalid interval requested %d", interval)
-		log.Println(errMsg)
-		return nil, errors.New(errMsg)
-	}
 	subNumber := interval / subInterval
 	startTime := candlestick.BlockToUnix(block, interval)
 	now := time.Now().UTC().Unix()
@@ -88,16 +113,16 @@ func FetchCandles(symbol string, block int64, interval int64, useCache bool) (*c
 
 		// Fetch sub block
 		b := candlestick.UnixToBlock(startTime, subInterval) + i
-		s, err := FetchCandles(symbol, b, subInterval, useCache)
-		if err != nil {
-			return nil, err
-		}
+		s, err := FetchCandles(symbol, b, subInterval, adjust, useCache)
 
 		// Handle empty block as incomplete data
-		if s == nil {
+		if isAbsent(err) {
 			isComplete = false
 			continue
 		}
+		if err != nil {
+			return nil, err
+		}
 
 		isComplete = isComplete && s.IsComplete()
 
@@ -126,9 +151,9 @@ func FetchCandles(symbol string, block int64, interval int64, useCache bool) (*c
 	}
 
 	if !isComplete {
-		candleSetCache.SetWithTTL(key, data, candleSetCost, 10*time.Second)
+		cacheSet(data, adjust, TypeCandles, 10*time.Second)
 	} else {
-		candleSetCache.Set(key, data, candleSetCost)
+		cacheSet(data, adjust, TypeCandles, 0)
 	}
 
 	return data, nil
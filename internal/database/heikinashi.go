@@ -0,0 +1,92 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"math"
+	"time"
+)
+
+// CandleType selects a transformation applied to the candles of a set
+type CandleType string
+
+const (
+	TypeCandles    = CandleType("candles")
+	TypeHeikinAshi = CandleType("heikin-ashi")
+)
+
+// heikinAshi transforms candles into heikin-ashi candles, continuing from the state of the previous candle
+// when prevOpen and prevClose are known, missing candles are skipped and keep the state
+func heikinAshi(candles []candlestick.Candle, prevOpen float64, prevClose float64, seeded bool) ([]candlestick.Candle, float64, float64, bool) {
+	result := make([]candlestick.Candle, len(candles))
+	for i := range candles {
+		c := candles[i]
+		if c.Missing {
+			result[i] = c
+			continue
+		}
+		haClose := (c.Open + c.High + c.Low + c.Close) / 4
+		haOpen := (c.Open + c.Close) / 2
+		if seeded {
+			haOpen = (prevOpen + prevClose) / 2
+		}
+		c.High = math.Max(c.High, math.Max(haOpen, haClose))
+		c.Low = math.Min(c.Low, math.Min(haOpen, haClose))
+		c.Open = haOpen
+		c.Close = haClose
+		result[i] = c
+		prevOpen, prevClose, seeded = haOpen, haClose, true
+	}
+	return result, prevOpen, prevClose, seeded
+}
+
+// FetchHeikinAshi returns the heikin-ashi transformation of a block, the previous block is used as warm-up so
+// the series is continuous across blocks since the influence of the seed halves with every candle
+func FetchHeikinAshi(symbol string, block int64, interval int64, adjust Adjustment, useCache bool) (*candlestick.CandleSet, error) {
+
+	key := getCacheKey(symbol, block, interval, adjust, TypeHeikinAshi)
+	if useCache {
+		if v, ok := candleSetCache.Get(key); ok {
+			return v.(*candlestick.CandleSet), nil
+		}
+	}
+
+	current, err := FetchCandles(symbol, block, interval, adjust, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	// the seed only matters when there is no previous block at all
+	previous, err := FetchCandles(symbol, block-1, interval, adjust, useCache)
+	if err != nil && !isAbsent(err) {
+		return nil, err
+	}
+	prevOpen, prevClose, seeded := 0.0, 0.0, false
+	if previous != nil {
+		_, prevOpen, prevClose, seeded = heikinAshi(previous.Candles, 0, 0, false)
+	}
+
+	candles, _, _, _ := heikinAshi(current.Candles, prevOpen, prevClose, seeded)
+
+	// a previous block which can still change alters the seed, only sets after the listing block are exempt
+	isComplete := current.IsComplete() && (errors.Is(err, ErrBeforeOnBoardDate) || (previous != nil && previous.IsComplete()))
+
+	data := &candlestick.CandleSet{
+		Candles: candles,
+		Meta:    current.Meta,
+	}
+	data.Meta.UID = current.UID() + ":" + string(TypeHeikinAshi)
+	data.Meta.Complete = isComplete
+
+	if !useCache {
+		return data, nil
+	}
+
+	if !isComplete {
+		cacheSet(data, adjust, TypeHeikinAshi, 10*time.Second)
+	} else {
+		cacheSet(data, adjust, TypeHeikinAshi, 0)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+	"math"
+	"testing"
+)
+
+func TestHeikinAshi(t *testing.T) {
+
+	candles := []candlestick.Candle{
+		{Open: 10, High: 14, Low: 9, Close: 12, Time: 0},
+		{Missing: true, Time: 60},
+		{Open: 12, High: 13, Low: 8, Close: 9, Time: 120},
+		{Open: 9, High: 11, Low: 7, Close: 10, Time: 180},
+	}
+
+	result, _, _, _ := heikinAshi(candles, 0, 0, false)
+
+	// the first candle is seeded from its own open and close
+	if result[0].Open != 11 || result[0].Close != 11.25 || result[0].High != 14 || result[0].Low != 9 {
+		t.Errorf("unexpected first candle %+v", result[0])
+	}
+	if !result[1].Missing {
+		t.Errorf("missing candle was filled")
+	}
+
+	// the state carries over missing candles
+	if result[2].Open != (11+11.25)/2 || result[2].Close != 10.5 {
+		t.Errorf("unexpected third candle %+v", result[2])
+	}
+
+	// transforming in two parts matches a single pass, as done across block boundaries
+	head, prevOpen, prevClose, seeded := heikinAshi(candles[:2], 0, 0, false)
+	tail, _, _, _ := heikinAshi(candles[2:], prevOpen, prevClose, seeded)
+	split := append(head, tail...)
+	for i := range result {
+		if math.Abs(split[i].Open-result[i].Open) > 1e-12 || math.Abs(split[i].Close-result[i].Close) > 1e-12 {
+			t.Errorf("candle %d differs when split: %+v != %+v", i, split[i], result[i])
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/ristretto"
+	"github.com/godoji/candlestick"
+	"kio/internal/metrics"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxIndicatorPeriod limits the period of an indicator, which bounds the number of warm-up candles
+const MaxIndicatorPeriod = 1000
+
+// maxWarmUpBlocks bounds the blocks searched for warm-up candles of symbols with sparse data
+const maxWarmUpBlocks = 64
+
+var (
+	ErrUnknownIndicator    = errors.New("unknown indicator")
+	ErrInvalidIndicatorArg = errors.New("invalid indicator parameters")
+)
+
+var indicatorCache *ristretto.Cache
+var indicatorCost = int64(candlestick.CandleSetSize) * 16
+
+type indicatorSpec struct {
+	defaults []int
+	// warmUp returns the number of preceding candles needed before values are reliable
+	warmUp  func(params []int) int
+	compute func(candles []candlestick.Candle, params []int) map[string]*candlestick.IndicatorSeries
+}
+
+// recursive averages never forget their seed, ten periods reduce its weight far below float precision of prices
+func recursiveWarmUp(params []int) int {
+	return 10 * params[0]
+}
+
+func windowWarmUp(params []int) int {
+	return params[0] - 1
+}
+
+var indicators = map[string]indicatorSpec{
+	"sma":       {defaults: []int{20}, warmUp: windowWarmUp, compute: computeSMA},
+	"ema":       {defaults: []int{20}, warmUp: recursiveWarmUp, compute: computeEMA},
+	"rsi":       {defaults: []int{14}, warmUp: recursiveWarmUp, compute: computeRSI},
+	"atr":       {defaults: []int{14}, warmUp: recursiveWarmUp, compute: computeATR},
+	"bollinger": {defaults: []int{20, 2}, warmUp: windowWarmUp, compute: computeBollinger},
+}
+
+func init() {
+	var err error
+	indicatorCache, err = ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1 << 12,
+		MaxCost:     (1 << 10) * indicatorCost,
+		BufferItems: 64,
+		Metrics:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	metrics.RegisterCache("indicators", indicatorCache.Metrics)
+
+	// indicators are not indexed by symbol, any purge drops all of them
+	OnCachePurge(func(symbol string) {
+		indicatorCache.Clear()
+	})
+}
+
+// IndicatorNames lists every supported indicator
+func IndicatorNames() []string {
+	return []string{"sma", "ema", "rsi", "atr", "bollinger"}
+}
+
+// indicatorParams validates the parameters of an indicator, falling back to its defaults when none are given
+func indicatorParams(name string, params []int) ([]int, error) {
+	spec, ok := indicators[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownIndicator, name)
+	}
+	if len(params) == 0 {
+		return spec.defaults, nil
+	}
+	if len(params) > len(spec.defaults) {
+		return nil, fmt.Errorf("%w: %s takes at most %d", ErrInvalidIndicatorArg, name, len(spec.defaults))
+	}
+	result := append(append(make([]int, 0, len(spec.defaults)), params...), spec.defaults[len(params):]...)
+	if result[0] < 1 || result[0] > MaxIndicatorPeriod {
+		return nil, fmt.Errorf("%w: period must be between 1 and %d", ErrInvalidIndicatorArg, MaxIndicatorPeriod)
+	}
+	for _, p := range result[1:] {
+		if p < 1 {
+			return nil, fmt.Errorf("%w: parameters must be positive", ErrInvalidIndicatorArg)
+		}
+	}
+	return result, nil
+}
+
+func joinParams(params []int) string {
+	s := make([]string, len(params))
+	for i, p := range params {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ",")
+}
+
+func getIndicatorKey(symbol string, block int64, interval int64, adjust Adjustment, name string, params []int) string {
+	return fmt.Sprintf("%s_%d_%d_%s_%s_%s", symbol, block, interval, adjust, name, joinParams(params))
+}
+
+func newSeries(n int, kind candlestick.SeriesType, axis candlestick.AxisType) *candlestick.IndicatorSeries {
+	values := make([]candlestick.IndicatorValue, n)
+	for i := range values {
+		values[i].Missing = true
+	}
+	return &candlestick.IndicatorSeries{Values: values, Kind: kind, Axis: axis}
+}
+
+// computeSMA averages the closes of the last period candles holding data
+func computeSMA(candles []candlestick.Candle, params []int) map[string]*candlestick.IndicatorSeries {
+	mean, _ := rollingWindow(candles, params[0])
+	return map[string]*candlestick.IndicatorSeries{"sma": mean}
+}
+
+// computeBollinger places bands the given number of standard deviations around the simple moving average
+func computeBollinger(candles []candlestick.Candle, params []int) map[string]*candlestick.IndicatorSeries {
+	mean, deviation := rollingWindow(candles, params[0])
+	upper := newSeries(len(candles), candlestick.LineChart, candlestick.PriceAxis)
+	lower := newSeries(len(candles), candlestick.LineChart, candlestick.PriceAxis)
+	for i := range mean.Values {
+		if mean.Values[i].Missing {
+			continue
+		}
+		width := float64(params[1]) * deviation[i]
+		upper.Values[i] = candlestick.IndicatorValue{Value: mean.Values[i].Value + width}
+		lower.Values[i] = candlestick.IndicatorValue{Value: mean.Values[i].Value - width}
+	}
+	return map[string]*candlestick.IndicatorSeries{"middle": mean, "upper": upper, "lower": lower}
+}
+
+// rollingWindow computes the mean and population standard deviation of the closes of the last period candles
+// holding data, missing candles are skipped and have no value
+func rollingWindow(candles []candlestick.Candle, period int) (*candlestick.IndicatorSeries, []float64) {
+	mean := newSeries(len(candles), candlestick.LineChart, candlestick.PriceAxis)
+	deviation := make([]float64, len(candles))
+	window := make([]float64, 0, period)
+	for i := range candles {
+		c := &candles[i]
+		if c.Missing {
+			continue
+		}
+		if len(window) == period {
+			window = window[1:]
+		}
+		window = append(window, c.Close)
+		if len(window) < period {
+			continue
+		}
+		sum := 0.0
+		for _, v := range window {
+			sum += v
+		}
+		m := sum / float64(period)
+		variance := 0.0
+		for _, v := range window {
+			variance += (v - m) * (v - m)
+		}
+		mean.Values[i] = candlestick.IndicatorValue{Value: m}
+		deviation[i] = math.Sqrt(variance / float64(period))
+	}
+	return mean, deviation
+}
+
+// computeEMA smooths closes exponentially, seeded with the simple average of the first period closes
+func computeEMA(candles []candlestick.Candle, params []int) map[string]*candlestick.IndicatorSeries {
+	period := params[0]
+	series := newSeries(len(candles), candlestick.LineChart, candlestick.PriceAxis)
+	alpha := 2 / float64(period+1)
+	ema, count := 0.0, 0
+	for i := range candles {
+		c := &candles[i]
+		if c.Missing {
+			continue
+		}
+		count++
+		if count <= period {
+			ema += c.Close / float64(period)
+			if count < period {
+				continue
+			}
+		} else {
+			ema += alpha * (c.Close - ema)
+		}
+		series.Values[i] = candlestick.IndicatorValue{Value: ema}
+	}
+	return map[string]*candlestick.IndicatorSeries{"ema": series}
+}
+
+// computeRSI computes the relative strength index with wilder smoothing of gains and losses
+func computeRSI(candles []candlestick.Candle, params []int) map[string]*candlestick.IndicatorSeries {
+	period := params[0]
+	series := newSeries(len(candles), candlestick.LineChart, candlestick.CustomAxis)
+	gain, loss := 0.0, 0.0
+	var prev *candlestick.Candle
+	count := 0
+	for i := range candles {
+		c := &candles[i]
+		if c.Missing {
+			continue
+		}
+		if prev == nil {
+			prev = c
+			continue
+		}
+		change := c.Close - prev.Close
+		prev = c
+		up, down := math.Max(change, 0), math.Max(-change, 0)
+		count++
+		if count <= period {
+			gain += up / float64(period)
+			loss += down / float64(period)
+			if count < period {
+				continue
+			}
+		} else {
+			gain = (gain*float64(period-1) + up) / float64(period)
+			loss = (loss*float64(period-1) + down) / float64(period)
+		}
+		rsi := 100.0
+		if loss > 0 {
+			rsi = 100 - 100/(1+gain/loss)
+		} else if gain == 0 {
+			rsi = 50
+		}
+		series.Values[i] = candlestick.IndicatorValue{Value: rsi}
+	}
+	return map[string]*candlestick.IndicatorSeries{"rsi": series}
+}
+
+// computeATR computes the average true range with wilder smoothing
+func computeATR(candles []candlestick.Candle, params []int) map[string]*candlestick.IndicatorSeries {
+	period := params[0]
+	series := newSeries(len(candles), candlestick.LineChart, candlestick.CustomAxis)
+	atr := 0.0
+	var prev *candlestick.Candle
+	count := 0
+	for i := range candles {
+		c := &candles[i]
+		if c.Missing {
+			continue
+		}
+		tr := c.High - c.Low
+		if prev != nil {
+			tr = math.Max(tr, math.Max(math.Abs(c.High-prev.Close), math.Abs(c.Low-prev.Close)))
+		}
+		prev = c
+		count++
+		if count <= period {
+			atr += tr / float64(period)
+			if count < period {
+				continue
+			}
+		} else {
+			atr = (atr*float64(period-1) + tr) / float64(period)
+		}
+		series.Values[i] = candlestick.IndicatorValue{Value: atr}
+	}
+	return map[string]*candlestick.IndicatorSeries{"atr": series}
+}
+
+// warmUpCandles collects the candles of the blocks preceding a block, in order, until they hold warmUp candles with
+// data, the listing date is reached or maxWarmUpBlocks were searched. Warm-up blocks before the listing date simply
+// do not exist, while blocks which are not downloaded leave the result incomplete.
+func warmUpCandles(block int64, warmUp int, fetch func(block int64) (*candlestick.CandleSet, error)) ([]candlestick.Candle, bool, error) {
+
+	isComplete := true
+	collected := 0
+	sets := make([]*candlestick.CandleSet, 0)
+	for b := block - 1; collected < warmUp && b >= block-maxWarmUpBlocks; b-- {
+		previous, err := fetch(b)
+		if errors.Is(err, ErrBeforeOnBoardDate) {
+			break
+		}
+		if isAbsent(err) {
+			isComplete = false
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		isComplete = isComplete && previous.IsComplete()
+		for i := range previous.Candles {
+			if !previous.Candles[i].Missing {
+				collected++
+			}
+		}
+		sets = append(sets, previous)
+	}
+
+	candles := make([]candlestick.Candle, 0, int64(len(sets)+1)*candlestick.CandleSetSize)
+	for i := len(sets) - 1; i >= 0; i-- {
+		candles = append(candles, sets[i].Candles...)
+	}
+	return candles, isComplete, nil
+}
+
+// FetchIndicator computes an indicator over the candles of a block, enough preceding blocks are included as
+// warm-up so values do not depend on where a block starts. Missing candles are skipped by every indicator, so
+// blocks are added until enough candles holding data are collected or the on board date is reached.
+func FetchIndicator(symbol string, block int64, interval int64, name string, params []int, adjust Adjustment, useCache bool) (*candlestick.Indicator, error) {
+
+	params, err := indicatorParams(name, params)
+	if err != nil {
+		return nil, err
+	}
+	spec := indicators[name]
+
+	key := getIndicatorKey(symbol, block, interval, adjust, name, params)
+	if useCache {
+		if v, ok := indicatorCache.Get(key); ok {
+			return v.(*candlestick.Indicator), nil
+		}
+	}
+
+	current, err := FetchCandles(symbol, block, interval, adjust, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	candles, warmUpComplete, err := warmUpCandles(block, spec.warmUp(params), func(b int64) (*candlestick.CandleSet, error) {
+		return FetchCandles(symbol, b, interval, adjust, useCache)
+	})
+	if err != nil {
+		return nil, err
+	}
+	isComplete := current.IsComplete() && warmUpComplete
+	offset := len(candles)
+	candles = append(candles, current.Candles...)
+
+	series := spec.compute(candles, params)
+	for _, s := range series {
+		s.Values = s.Values[offset:]
+	}
+
+	data := &candlestick.Indicator{
+		Series: series,
+		Meta: candlestick.IndicatorMeta{
+			UID:          current.UID() + ":" + name + ":" + joinParams(params),
+			Block:        block,
+			Complete:     isComplete,
+			LastUpdate:   current.LastUpdate(),
+			Symbol:       symbol,
+			Interval:     interval,
+			BaseInterval: interval,
+			Name:         name,
+			Parameters:   params,
+		},
+	}
+
+	if !useCache {
+		return data, nil
+	}
+
+	if !isComplete {
+		indicatorCache.SetWithTTL(key, data, indicatorCost, 10*time.Second)
+	} else {
+		indicatorCache.Set(key, data, indicatorCost)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"math"
+	"testing"
+)
+
+func closes(values ...float64) []candlestick.Candle {
+	candles := make([]candlestick.Candle, len(values))
+	for i, v := range values {
+		candles[i] = candlestick.Candle{Open: v, High: v, Low: v, Close: v, Time: int64(i) * 60}
+		if math.IsNaN(v) {
+			candles[i] = candlestick.Candle{Missing: true, Time: int64(i) * 60}
+		}
+	}
+	return candles
+}
+
+func TestIndicatorParams(t *testing.T) {
+	if params, err := indicatorParams("bollinger", []int{10}); err != nil || params[0] != 10 || params[1] != 2 {
+		t.Errorf("defaults were not completed: %v %v", params, err)
+	}
+	if _, err := indicatorParams("sma", []int{MaxIndicatorPeriod + 1}); !errors.Is(err, ErrInvalidIndicatorArg) {
+		t.Errorf("large period was accepted")
+	}
+	if _, err := indicatorParams("sma", []int{5, 2}); !errors.Is(err, ErrInvalidIndicatorArg) {
+		t.Errorf("extra parameters were accepted")
+	}
+	if _, err := indicatorParams("macd", nil); !errors.Is(err, ErrUnknownIndicator) {
+		t.Errorf("unknown indicator was accepted")
+	}
+}
+
+func TestSMASkipsMissing(t *testing.T) {
+	series := computeSMA(closes(1, 2, math.NaN(), 3, 4), []int{3})["sma"]
+	expected := []candlestick.IndicatorValue{{Missing: true}, {Missing: true}, {Missing: true}, {Value: 2}, {Value: 3}}
+	for i := range expected {
+		if series.Values[i] != expected[i] {
+			t.Errorf("value %d is %+v, expected %+v", i, series.Values[i], expected[i])
+		}
+	}
+}
+
+func TestEMA(t *testing.T) {
+	series := computeEMA(closes(1, 2, 3, 6), []int{3})["ema"]
+	if !series.Values[1].Missing || series.Values[2].Value != 2 || series.Values[3].Value != 4 {
+		t.Errorf("unexpected ema %+v", series.Values)
+	}
+}
+
+func TestRSI(t *testing.T) {
+	series := computeRSI(closes(1, 2, 3, 2), []int{2})["rsi"]
+	if !series.Values[1].Missing || series.Values[2].Value != 100 {
+		t.Errorf("unexpected rsi %+v", series.Values)
+	}
+	// gain 0.5 and loss 0.5 after smoothing
+	if math.Abs(series.Values[3].Value-50) > 1e-9 {
+		t.Errorf("unexpected rsi %f", series.Values[3].Value)
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	series := computeBollinger(closes(1, 3), []int{2, 2})
+	if series["middle"].Values[1].Value != 2 || series["upper"].Values[1].Value != 4 || series["lower"].Values[1].Value != 0 {
+		t.Errorf("unexpected bands %+v", series)
+	}
+}
+
+func TestWarmUpCountsCandlesWithData(t *testing.T) {
+
+	// every earlier block holds a single candle with data, so the warm-up has to reach three blocks back
+	fetched := make([]int64, 0)
+	fetch := func(block int64) (*candlestick.CandleSet, error) {
+		fetched = append(fetched, block)
+		if block < 7 {
+			return nil, ErrBeforeOnBoardDate
+		}
+		set := &candlestick.CandleSet{Candles: make([]candlestick.Candle, 3), Meta: candlestick.DataSetMeta{Block: block, Complete: true}}
+		for i := range set.Candles {
+			set.Candles[i] = candlestick.Candle{Missing: i != 0, Close: float64(block)}
+		}
+		return set, nil
+	}
+
+	candles, isComplete, err := warmUpCandles(10, 3, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 3 || len(candles) != 9 || !isComplete {
+		t.Fatalf("fetched %v into %d candles", fetched, len(candles))
+	}
+	if candles[0].Close != 7 || candles[8].Close != 9 {
+		t.Errorf("warm-up blocks out of order")
+	}
+
+	// the listing date ends the search before enough candles were found
+	fetched = fetched[:0]
+	candles, _, err = warmUpCandles(10, 10, fetch)
+	if err != nil || len(fetched) != 4 || len(candles) != 9 {
+		t.Errorf("fetched %v into %d candles, %v", fetched, len(candles), err)
+	}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"fmt"
+	"github.com/godoji/candlestick"
+)
+
+// MaxCustomSubBlocks limits the number of sub blocks aggregated into a single block of a custom interval
+const MaxCustomSubBlocks = 500
+
+// IsNativeInterval reports whether an interval is one of the intervals of the candlestick library
+func IsNativeInterval(interval int64) bool {
+	for _, candidate := range candlestick.IntervalList {
+		if candidate == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// servable checks whether a native interval can be built from the primitive resolutions of an exchange
+func servable(interval int64, resolutions []int64) bool {
+	for _, resolution := range resolutions {
+		if resolution == interval {
+			return true
+		}
+	}
+	sub, ok := candlestick.IntervalMap[interval]
+	return ok && servable(sub, resolutions)
+}
+
+// SubInterval selects the interval from which a non-primitive interval is aggregated, native intervals follow
+// the candlestick library while custom intervals use the largest servable interval dividing them
+func SubInterval(interval int64, resolutions []int64) (int64, error) {
+
+	if sub, ok := candlestick.IntervalMap[interval]; ok {
+		return sub, nil
+	}
+
+	best := int64(0)
+	candidates := append(append(make([]int64, 0), resolutions...), candlestick.IntervalList...)
+	for _, candidate := range candidates {
+		if candidate >= interval || interval%candidate != 0 || candidate <= best {
+			continue
+		}
+		if servable(candidate, resolutions) {
+			best = candidate
+		}
+	}
+
+	if best == 0 {
+		return 0, fmt.Errorf("%w %d: not a multiple of a primitive resolution", ErrInvalidInterval, interval)
+	}
+	if interval/best > MaxCustomSubBlocks {
+		return 0, fmt.Errorf("%w %d: requires more than %d sub blocks", ErrInvalidInterval, interval, MaxCustomSubBlocks)
+	}
+
+	return best, nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func TestSubInterval(t *testing.T) {
+
+	resolutions := []int64{candlestick.Interval1m}
+
+	cases := map[int64]int64{
+		candlestick.Interval15m: candlestick.Interval5m,  // native intervals follow the library
+		7 * 60:                  candlestick.Interval1m,  // 7 minutes
+		candlestick.Interval45m: candlestick.Interval15m, // native
+		5 * 3600:                candlestick.Interval1h,  // 5 hours
+		7 * 86400:               candlestick.Interval1d,  // 1 week
+	}
+
+	for interval, expected := range cases {
+		sub, err := SubInterval(interval, resolutions)
+		if err != nil {
+			t.Fatalf("interval %d: %v", interval, err)
+		}
+		if sub != expected {
+			t.Errorf("interval %d: expected sub interval %d but got %d", interval, expected, sub)
+		}
+	}
+
+	// not a multiple of the primitive resolution
+	if _, err := SubInterval(90, resolutions); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected invalid interval error but got %v", err)
+	}
+
+	// too many sub blocks since 503 is prime
+	if _, err := SubInterval(503*candlestick.Interval1m, resolutions); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected invalid interval error but got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+)
+
+// LatestCandleTime returns the open time of the most recent candle holding data in a real-time block
+func LatestCandleTime(data *candlestick.CandleSet) (int64, bool) {
+	for i := len(data.Candles) - 1; i >= 0; i-- {
+		if !data.Candles[i].Missing {
+			return data.Candles[i].Time, true
+		}
+	}
+	return 0, false
+}
+
+// AggregatePeriod merges all primitive candles of a real-time block into a single candle of the given
+// interval starting at periodStart, reaching back into the previous block when the period starts before it
+func AggregatePeriod(data *candlestick.CandleSet, periodStart int64, interval int64) (candlestick.Candle, error) {
+
+	result := candlestick.Candle{
+		Time:    periodStart,
+		Missing: true,
+	}
+	periodEnd := periodStart + interval
+
+	// merge the part of the period which lies in previous blocks, unadjusted like the real-time block itself
+	for b := candlestick.UnixToBlock(periodStart, data.Interval()); b < data.BlockNumber(); b++ {
+		prev, err := primitiveSet(data.Symbol(), b, data.Interval(), AdjustNone)
+		if err != nil {
+			return result, err
+		}
+		if prev == nil {
+			continue
+		}
+		for i := range prev.Candles {
+			if prev.Candles[i].Time >= periodStart {
+				mergeCandles(&prev.Candles[i], &result)
+			}
+		}
+	}
+
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		if c.Time < periodStart {
+			continue
+		}
+		if c.Time >= periodEnd {
+			break
+		}
+		mergeCandles(c, &result)
+	}
+
+	return result, nil
+}
+
+// PeriodStart returns the open time of the candle of the given interval containing timestamp t
+func PeriodStart(t int64, interval int64) int64 {
+	return alignTime(t, interval)
+}
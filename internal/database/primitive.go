@@ -7,26 +7,16 @@ import (
 	"time"
 )
 
-func primitiveSet(symbol string, block int64, resolution int64) (*candlestick.CandleSet, error) {
-
-	// try cache first
-	key := getCacheKey(symbol, block, resolution)
+func primitiveSet(symbol string, block int64, resolution int64, adjust Adjustment) (*candlestick.CandleSet, error) {
 
 	// check if block is in memory or on disk
-	cbn := CacheBlockNumber(resolution)
-	if cbn == block {
-
-		result := RealTimeBlock(symbol, block)
-
-		// make sure we got the correct block, otherwise check disk
-		if result.BlockNumber() == block {
-			candleSetCache.SetWithTTL(key, result, candleSetCost, 10*time.Second)
-			return result, nil
-		}
-
+	if result := RealTimeBlock(symbol, block); result != nil && result.Interval() == resolution {
+		cacheSet(result, adjust, TypeCandles, 10*time.Second)
+		return result, nil
 	}
 
 	// check if block is from the future
+	cbn := realTimeBlockNumber(symbol, resolution)
 	if cbn != math.MinInt64 && cbn < block {
 		return nil, nil
 	}
@@ -37,23 +27,16 @@ func primitiveSet(symbol string, block int64, resolution int64) (*candlestick.Ca
 		return nil, err
 	}
 
-	info := store.AssetInfo(symbol)
-	if result != nil && len(info.Splits) > 0 {
-		for i := range result.Candles {
-			for j := len(info.Splits) - 1; j >= 0; j-- {
-				split := info.Splits[j]
-				if result.Candles[i].Time >= split.Time {
-					break
-				}
-				result.Candles[i].Open /= split.Ratio
-				result.Candles[i].High /= split.Ratio
-				result.Candles[i].Low /= split.Ratio
-				result.Candles[i].Close /= split.Ratio
-			}
+	if result != nil {
+		if err = adjustSet(result, adjust); err != nil {
+			return nil, err
 		}
 	}
 
-	candleSetCache.Set(key, result, candleSetCost)
+	// blocks which are not on disk yet are not cached, they may be downloaded at any moment
+	if result != nil {
+		cacheSet(result, adjust, TypeCandles, 0)
+	}
 
 	return result, err
 }
@@ -65,6 +48,7 @@ func mergeCandles(src *candlestick.Candle, dst *candlestick.Candle) {
 	if dst.Missing {
 		dst.Missing = false
 		dst.Open = src.Open
+		dst.High = src.High
 		dst.Low = src.Low
 	}
 	if src.High > dst.High {
@@ -59,3 +59,12 @@ func TestCandleMerge(t *testing.T) {
 	}
 
 }
+
+func TestCandleMergeNegative(t *testing.T) {
+	src := candlestick.Candle{Open: -5, High: -2, Low: -8, Close: -3}
+	dst := candlestick.Candle{Missing: true}
+	mergeCandles(&src, &dst)
+	if dst.High != -2 || dst.Low != -8 {
+		t.Errorf("unexpected range of negative candle %+v", dst)
+	}
+}
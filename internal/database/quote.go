@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"math"
+	"strings"
+)
+
+var ErrNoConversionRates = errors.New("conversion rates are not available")
+
+// convertCandle converts the prices of a candle with the rates at the open and close of the same period, the high
+// and low take the more extreme of both rates since the rate within the period is unknown
+func convertCandle(c *candlestick.Candle, openRate float64, closeRate float64) {
+	c.Open *= openRate
+	c.Close *= closeRate
+	c.High *= math.Max(openRate, closeRate)
+	c.Low *= math.Min(openRate, closeRate)
+}
+
+// stepRates returns the rates of a conversion step at the open and close of a candle
+func stepRates(c *candlestick.Candle, inverse bool) (float64, float64, bool) {
+	if c.Missing || c.Open <= 0 || c.Close <= 0 {
+		return 0, 0, false
+	}
+	if inverse {
+		return 1 / c.Open, 1 / c.Close, true
+	}
+	return c.Open, c.Close, true
+}
+
+// convertedUID identifies a converted set by the quote asset and the path it was converted through
+func convertedUID(uid string, quote string, path []store.ConversionStep) string {
+	steps := make([]string, len(path))
+	for i, step := range path {
+		steps[i] = step.String()
+	}
+	return uid + ":" + quote + "@" + strings.Join(steps, ",")
+}
+
+// FetchConvertedCandles returns a block with prices converted into another quote asset along with the conversion
+// path, candles are missing whenever a symbol on the path is missing a candle, while a symbol missing the whole
+// block fails the conversion. Rates are never adjusted, as the price of a candle converts at the rate of its own
+// time whatever adjustment the candle itself carries. Converted sets are not cached as they are cheap to derive from the cached sets of
+// the path
+func FetchConvertedCandles(symbol string, block int64, interval int64, quote string, adjust Adjustment, useCache bool) (*candlestick.CandleSet, []store.ConversionStep, error) {
+
+	info := store.AssetInfo(symbol)
+	if info == nil {
+		return nil, nil, ErrSymbolNotFound
+	}
+
+	path, err := store.ConversionPath(info.QuoteAsset, quote)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := FetchCandles(symbol, block, interval, adjust, useCache)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(path) == 0 {
+		return current, path, nil
+	}
+
+	candles := append(make([]candlestick.Candle, 0, len(current.Candles)), current.Candles...)
+	isComplete := current.IsComplete()
+	for _, step := range path {
+		rates, err := FetchCandles(step.Symbol, block, interval, AdjustNone, useCache)
+		if isAbsent(err) {
+			return nil, nil, fmt.Errorf("%w: %s block %d: %v", ErrNoConversionRates, step.Symbol, block, err)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		isComplete = isComplete && rates.IsComplete()
+		for i := range candles {
+			c := &candles[i]
+			if c.Missing {
+				continue
+			}
+			openRate, closeRate, ok := stepRates(&rates.Candles[i], step.Inverse)
+			if !ok {
+				*c = candlestick.Candle{Time: c.Time, Missing: true}
+				continue
+			}
+			convertCandle(c, openRate, closeRate)
+		}
+	}
+
+	data := &candlestick.CandleSet{
+		Candles: candles,
+		Meta:    current.Meta,
+	}
+	data.Meta.UID = convertedUID(current.UID(), quote, path)
+	data.Meta.Complete = isComplete
+
+	return data, path, nil
+}
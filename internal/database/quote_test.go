@@ -0,0 +1,30 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"testing"
+)
+
+func TestConvertCandle(t *testing.T) {
+	c := candlestick.Candle{Open: 10, High: 12, Low: 8, Close: 11, Volume: 3}
+	openRate, closeRate, ok := stepRates(&candlestick.Candle{Open: 2, Close: 4}, true)
+	if !ok {
+		t.Fatalf("rates were not derived")
+	}
+	convertCandle(&c, openRate, closeRate)
+	if c.Open != 5 || c.Close != 2.75 || c.High != 6 || c.Low != 2 || c.Volume != 3 {
+		t.Errorf("unexpected conversion %+v", c)
+	}
+	if _, _, ok = stepRates(&candlestick.Candle{Missing: true}, false); ok {
+		t.Errorf("missing candle has rates")
+	}
+}
+
+func TestConvertedUID(t *testing.T) {
+	path := []store.ConversionStep{{Symbol: "BINANCE:SPOT:BTCUSDT"}, {Symbol: "KRAKEN:SPOT:EURUSDT", Inverse: true}}
+	uid := convertedUID("BINANCE:SPOT:ETHBTC:60:7", "EUR", path)
+	if uid != "BINANCE:SPOT:ETHBTC:60:7:EUR@BINANCE:SPOT:BTCUSDT,1/KRAKEN:SPOT:EURUSDT" {
+		t.Errorf("unexpected uid %s", uid)
+	}
+}
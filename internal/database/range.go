@@ -0,0 +1,95 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+	"strconv"
+	"time"
+)
+
+// FetchCandleRange stitches the blocks covering [from, to) into a single contiguous candle set
+func FetchCandleRange(symbol string, from int64, to int64, interval int64, adjust Adjustment, useCache bool) (*candlestick.CandleSet, error) {
+	return stitchCandleRange(symbol, from, to, interval, func(block int64) (*candlestick.CandleSet, error) {
+		return FetchCandles(symbol, block, interval, adjust, useCache)
+	})
+}
+
+// stitchCandleRange joins the blocks returned by fetch over [from, to), blocks which are absent are filled with
+// missing candles
+func stitchCandleRange(symbol string, from int64, to int64, interval int64, fetch func(block int64) (*candlestick.CandleSet, error)) (*candlestick.CandleSet, error) {
+
+	// Align window on candle boundaries
+	from = alignTime(from, interval)
+	to = alignTime(to+interval-1, interval)
+	if to <= from {
+		return nil, ErrBlockNotDownloaded
+	}
+
+	firstBlock := candlestick.UnixToBlock(from, interval)
+	lastBlock := candlestick.UnixToBlock(to-interval, interval)
+	candles := make([]candlestick.Candle, 0, (to-from)/interval)
+
+	// Keep track of set completion and whether any data was found at all
+	isComplete := true
+	hasData := false
+
+	for block := firstBlock; block <= lastBlock; block++ {
+
+		s, err := fetch(block)
+		if err != nil && !isAbsent(err) {
+			return nil, err
+		}
+
+		// Fill blocks without data with missing candles to keep the series contiguous
+		if err != nil {
+			isComplete = false
+			blockStart := candlestick.BlockToUnix(block, interval)
+			blockEnd := candlestick.BlockToUnix(block+1, interval)
+			for t := blockStart; t < blockEnd; t += interval {
+				if t < from || t >= to {
+					continue
+				}
+				candles = append(candles, candlestick.Candle{
+					Time:    t,
+					Missing: true,
+				})
+			}
+			continue
+		}
+
+		hasData = true
+		isComplete = isComplete && s.IsComplete()
+
+		for i := range s.Candles {
+			c := s.Candles[i]
+			if c.Time < from || c.Time >= to {
+				continue
+			}
+			candles = append(candles, c)
+		}
+	}
+
+	if !hasData {
+		return nil, ErrBlockNotDownloaded
+	}
+
+	return &candlestick.CandleSet{
+		Candles: candles,
+		Meta: candlestick.DataSetMeta{
+			UID:        symbol + ":" + strconv.FormatInt(interval, 10) + ":" + strconv.FormatInt(from, 10) + "-" + strconv.FormatInt(to, 10),
+			Block:      firstBlock,
+			Complete:   isComplete,
+			LastUpdate: time.Now().UTC().Unix(),
+			Symbol:     symbol,
+			Interval:   interval,
+		},
+	}, nil
+}
+
+// alignTime rounds a timestamp down to the start of its candle, also for negative timestamps
+func alignTime(t int64, interval int64) int64 {
+	offset := t % interval
+	if offset < 0 {
+		offset += interval
+	}
+	return t - offset
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func rangeBlock(block int64, interval int64) *candlestick.CandleSet {
+	data := &candlestick.CandleSet{
+		Candles: make([]candlestick.Candle, candlestick.CandleSetSize),
+		Meta:    candlestick.DataSetMeta{Block: block, Interval: interval, Complete: true},
+	}
+	for i := range data.Candles {
+		t := candlestick.BlockToUnix(block, interval) + int64(i)*interval
+		data.Candles[i] = candlestick.Candle{Close: float64(t), Time: t}
+	}
+	return data
+}
+
+func TestStitchCandleRange(t *testing.T) {
+
+	const interval = 60
+	blockSpan := candlestick.CandleSetSize * int64(interval)
+	fetch := func(block int64) (*candlestick.CandleSet, error) {
+		if block == 11 {
+			return nil, ErrBlockNotDownloaded
+		}
+		return rangeBlock(block, interval), nil
+	}
+
+	// an unaligned window over three blocks, the middle one absent
+	from := candlestick.BlockToUnix(10, interval) + blockSpan - 2*interval - 7
+	to := candlestick.BlockToUnix(12, interval) + 2*interval + 7
+	result, err := stitchCandleRange("TEST:SPOT:RANGE", from, to, interval, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsComplete() {
+		t.Errorf("range with an absent block is complete")
+	}
+	if n := int64(len(result.Candles)); n != candlestick.CandleSetSize+6 {
+		t.Fatalf("got %d candles", n)
+	}
+	for i, c := range result.Candles {
+		if c.Time != PeriodStart(from, interval)+int64(i)*interval {
+			t.Fatalf("candle %d at %d breaks the series", i, c.Time)
+		}
+		absent := c.Time >= candlestick.BlockToUnix(11, interval) && c.Time < candlestick.BlockToUnix(12, interval)
+		if c.Missing != absent || (!absent && c.Close != float64(c.Time)) {
+			t.Fatalf("unexpected candle %d %+v", i, c)
+		}
+	}
+
+	// a range without any data fails
+	if _, err = stitchCandleRange("TEST:SPOT:RANGE", candlestick.BlockToUnix(11, interval), candlestick.BlockToUnix(12, interval), interval, fetch); !errors.Is(err, ErrBlockNotDownloaded) {
+		t.Errorf("range of absent blocks returned %v", err)
+	}
+}
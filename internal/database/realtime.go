@@ -2,9 +2,13 @@ package database
 
 import (
 	"github.com/godoji/candlestick"
+	"kio/internal/store"
 	"log"
 	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 func RunRealTimeService() (chan interface{}, chan interface{}) {
@@ -37,23 +41,177 @@ func checkTerminated(stop chan interface{}) bool {
 var realTimeCache = make(map[string]*candlestick.CandleSet)
 var realTimeCacheLock = sync.Mutex{}
 
+// realTimeFinished keeps the block a symbol left behind in memory until the audit stores it on disk, so periods
+// spanning both blocks can still be aggregated
+var realTimeFinished = make(map[string]*candlestick.CandleSet)
+
 func CacheBlockNumber(resolution int64) int64 {
-	if lastUpdate == 0 {
+	t := atomic.LoadInt64(&lastUpdate)
+	if t == 0 {
 		return math.MinInt64
 	}
-	return candlestick.UnixToBlock(lastUpdate, resolution)
+	return candlestick.UnixToBlock(t, resolution)
 }
 
-func RealTimeBlock(symbol string, block int64) *candlestick.CandleSet {
+// realTimeBlockNumber returns the block of a resolution the real-time data of a symbol has reached, falling back
+// to the real-time service when the symbol is not refreshed on its own
+func realTimeBlockNumber(symbol string, resolution int64) int64 {
 	realTimeCacheLock.Lock()
 	data := realTimeCache[symbol]
 	realTimeCacheLock.Unlock()
-	if data == nil || data.BlockNumber() != block {
-		return nil
+	if data == nil {
+		return CacheBlockNumber(resolution)
+	}
+	return candlestick.UnixToBlock(data.LastUpdate(), resolution)
+}
+
+// LatestRealTimeBlock returns the most recent real-time block of a symbol, nil when it is not refreshed
+func LatestRealTimeBlock(symbol string) *candlestick.CandleSet {
+	realTimeCacheLock.Lock()
+	defer realTimeCacheLock.Unlock()
+	return realTimeCache[symbol]
+}
+
+var realTimeListeners = make([]func(data *candlestick.CandleSet), 0)
+var realTimeListenersLock = sync.Mutex{}
+
+// OnRealTimeUpdate registers a listener which is called every time a real-time block changes
+func OnRealTimeUpdate(listener func(data *candlestick.CandleSet)) {
+	realTimeListenersLock.Lock()
+	realTimeListeners = append(realTimeListeners, listener)
+	realTimeListenersLock.Unlock()
+}
+
+func notifyRealTimeListeners(data *candlestick.CandleSet) {
+	realTimeListenersLock.Lock()
+	listeners := realTimeListeners
+	realTimeListenersLock.Unlock()
+	for _, listener := range listeners {
+		listener(data)
+	}
+}
+
+func updateRealTimeBlock(symbol string, data *candlestick.CandleSet) {
+	realTimeCacheLock.Lock()
+	realTimeCache[symbol] = data
+	realTimeCacheLock.Unlock()
+	notifyRealTimeListeners(data)
+}
+
+// realTimeLookBack is how far back candles are requested on every refresh, the bridge returns at most 99 candles
+const realTimeLookBack = int64(90 * 60)
+
+// mergeLatestCandles overwrites the candles of a set with the fetched candles of the same time
+func mergeLatestCandles(data *candlestick.CandleSet, candles []candlestick.Candle) {
+	for _, c := range candles {
+		if c.Time < data.UnixFirst() || c.Time > data.UnixLast() || (c.Time-data.UnixFirst())%data.Interval() != 0 {
+			continue
+		}
+		data.Candles[data.Index(c.Time)] = c
+	}
+}
+
+// emptyRealTimeSet creates a block of missing candles
+func emptyRealTimeSet(symbol string, block int64, resolution int64) *candlestick.CandleSet {
+	data := &candlestick.CandleSet{
+		Candles: make([]candlestick.Candle, candlestick.CandleSetSize),
+		Meta: candlestick.DataSetMeta{
+			UID:      symbol + ":" + strconv.FormatInt(resolution, 10) + ":" + strconv.FormatInt(block, 10),
+			Block:    block,
+			Symbol:   symbol,
+			Interval: resolution,
+		},
+	}
+	for i := range data.Candles {
+		data.Candles[i] = candlestick.Candle{Time: data.TimeStampAtIndex(int64(i)), Missing: true}
 	}
 	return data
 }
 
+// RefreshRealTimeBlock requests the latest candles of a symbol from the bridge and merges them into its real-time
+// block, the updated block is passed to the real-time listeners
+func RefreshRealTimeBlock(symbol string) error {
+
+	resolution, err := smallestResolution(symbol)
+	if err != nil {
+		return err
+	}
+
+	realTimeCacheLock.Lock()
+	previous := realTimeCache[symbol]
+	realTimeCacheLock.Unlock()
+
+	// only the candles since the last refresh can have changed, with some margin for late corrections
+	now := time.Now().UTC().Unix()
+	from := now - realTimeLookBack
+	if previous != nil && previous.LastUpdate()-5*60 > from {
+		from = previous.LastUpdate() - 5*60
+	}
+	candles, err := store.RequestCandlesFromTime(alignTime(from, resolution), symbol)
+	if err != nil {
+		return err
+	}
+
+	return applyRealTimeCandles(symbol, resolution, now, candles)
+}
+
+// applyRealTimeCandles merges candles into a copy of the real-time block of a symbol, since readers may still hold
+// the previous version, and replaces it. A block which was left behind is completed one last time and kept in
+// memory, storing it on disk is left to the audit.
+func applyRealTimeCandles(symbol string, resolution int64, now int64, candles []candlestick.Candle) error {
+
+	block := candlestick.UnixToBlock(now, resolution)
+
+	realTimeCacheLock.Lock()
+	previous := realTimeCache[symbol]
+	realTimeCacheLock.Unlock()
+
+	var data *candlestick.CandleSet
+	if previous != nil && previous.BlockNumber() == block {
+		data = &candlestick.CandleSet{
+			Candles: append(make([]candlestick.Candle, 0, len(previous.Candles)), previous.Candles...),
+			Meta:    previous.Meta,
+		}
+	} else {
+		if previous != nil {
+			finished := &candlestick.CandleSet{
+				Candles: append(make([]candlestick.Candle, 0, len(previous.Candles)), previous.Candles...),
+				Meta:    previous.Meta,
+			}
+			mergeLatestCandles(finished, candles)
+			finished.Meta.LastUpdate = now
+			realTimeCacheLock.Lock()
+			realTimeFinished[symbol] = finished
+			realTimeCacheLock.Unlock()
+		}
+		var err error
+		if data, err = store.LoadFromDisk(symbol, block, resolution); err != nil {
+			return err
+		}
+		if data == nil {
+			data = emptyRealTimeSet(symbol, block, resolution)
+		}
+	}
+
+	mergeLatestCandles(data, candles)
+	data.Meta.LastUpdate = now
+	data.Meta.Complete = false
+
+	updateRealTimeBlock(symbol, data)
+	return nil
+}
+
+func RealTimeBlock(symbol string, block int64) *candlestick.CandleSet {
+	realTimeCacheLock.Lock()
+	defer realTimeCacheLock.Unlock()
+	for _, data := range []*candlestick.CandleSet{realTimeCache[symbol], realTimeFinished[symbol]} {
+		if data != nil && data.BlockNumber() == block {
+			return data
+		}
+	}
+	return nil
+}
+
 //const cycleLength = int64(20) // number of seconds per fetch cycle
 // var fetchHasFailed = 0
 //
@@ -110,9 +268,7 @@ func RealTimeBlock(symbol string, block int64) *candlestick.CandleSet {
 //			}
 //			if data != nil {
 //				// log.Printf("recent data was found for %s, skipping download\n", s.Symbol)
-//				realTimeCacheLock.Lock()
-//				realTimeCache[s.Identifier.ToString()] = data
-//				realTimeCacheLock.Unlock()
+//				updateRealTimeBlock(s.Identifier.ToString(), data)
 //				continue
 //			}
 //		}
@@ -127,9 +283,7 @@ func RealTimeBlock(symbol string, block int64) *candlestick.CandleSet {
 //			if err != nil {
 //				log.Fatal(err)
 //			}
-//			realTimeCacheLock.Lock()
-//			realTimeCache[symbol] = blocks[0]
-//			realTimeCacheLock.Unlock()
+//			updateRealTimeBlock(symbol, blocks[0])
 //		}, s.Identifier.ToString())
 //
 //		// check if stop was triggered
@@ -293,6 +447,9 @@ func RealTimeBlock(symbol string, block int64) *candlestick.CandleSet {
 //	// remove cache entry
 //	clearCacheEntry(segment.Symbol(), segment.BlockNumber())
 //
+//	// notify live listeners of the updated block
+//	notifyRealTimeListeners(segment)
+//
 //	// switch to next segment
 //	if segment.Meta.Complete {
 //
@@ -347,12 +504,10 @@ func RealTimeBlock(symbol string, block int64) *candlestick.CandleSet {
 //			}
 //		}
 //
-//		realTimeCacheLock.Lock()
-//		realTimeCache[symbol] = segment
-//		realTimeCacheLock.Unlock()
+//		updateRealTimeBlock(symbol, segment)
 //	}
 //}
 
 func LastUpdateTime() int64 {
-	return lastUpdate
+	return atomic.LoadInt64(&lastUpdate)
 }
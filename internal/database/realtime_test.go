@@ -0,0 +1,71 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func TestApplyRealTimeCandles(t *testing.T) {
+
+	const symbol = "TEST:SPOT:REALTIME"
+	defer func() {
+		realTimeCacheLock.Lock()
+		delete(realTimeCache, symbol)
+		delete(realTimeFinished, symbol)
+		realTimeCacheLock.Unlock()
+	}()
+	updates := make([]*candlestick.CandleSet, 0)
+	OnRealTimeUpdate(func(data *candlestick.CandleSet) {
+		if data.Symbol() == symbol {
+			updates = append(updates, data)
+		}
+	})
+
+	start := candlestick.BlockToUnix(6000, 60)
+	first := []candlestick.Candle{{Open: 1, High: 2, Low: 1, Close: 2, Time: start}}
+	if err := applyRealTimeCandles(symbol, 60, start+30, first); err != nil {
+		t.Fatal(err)
+	}
+	second := []candlestick.Candle{{Open: 2, High: 3, Low: 2, Close: 3, Time: start + 60}}
+	if err := applyRealTimeCandles(symbol, 60, start+90, second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 updates, got %d", len(updates))
+	}
+	if updates[0].Candles[0].Close != 2 || !updates[0].Candles[1].Missing {
+		t.Errorf("unexpected first update %+v", updates[0].Candles[:2])
+	}
+
+	// later updates keep earlier candles without changing the block handed out before
+	if updates[1].Candles[0].Close != 2 || updates[1].Candles[1].Close != 3 || updates[1].LastUpdate() != start+90 {
+		t.Errorf("unexpected second update %+v", updates[1].Candles[:2])
+	}
+	if !updates[0].Candles[1].Missing {
+		t.Errorf("previous block was modified")
+	}
+	if RealTimeBlock(symbol, 6000) != updates[1] {
+		t.Errorf("real-time block was not replaced")
+	}
+
+	// a new block keeps the finished one in memory instead of storing it
+	next := candlestick.BlockToUnix(6001, 60)
+	third := []candlestick.Candle{{Open: 3, High: 4, Low: 3, Close: 4, Time: next}}
+	if err := applyRealTimeCandles(symbol, 60, next+30, third); err != nil {
+		t.Fatal(err)
+	}
+	finished := RealTimeBlock(symbol, 6000)
+	if finished == nil || finished.Candles[1].Close != 3 || finished == updates[1] {
+		t.Fatalf("finished block was not kept")
+	}
+	if current := LatestRealTimeBlock(symbol); current.BlockNumber() != 6001 || current.Candles[0].Close != 4 {
+		t.Errorf("unexpected current block %d", current.BlockNumber())
+	}
+	if n := realTimeBlockNumber(symbol, 60); n != 6001 {
+		t.Errorf("symbol reached block %d", n)
+	}
+	if LastUpdateTime() != 0 {
+		t.Errorf("refreshing a symbol changed the last update of the real-time service")
+	}
+}
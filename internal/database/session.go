@@ -0,0 +1,200 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"strconv"
+	"time"
+)
+
+var ErrNoSession = errors.New("exchange has no trading session defined")
+
+// MaxSessionSourceCandles limits the number of source candles read to build a single block of session candles
+const MaxSessionSourceCandles = 50 * candlestick.CandleSetSize
+
+// typeSession keys session anchored candle sets in the candle set cache
+const typeSession = CandleType("session")
+
+func gcd(a int64, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// sessionSourceInterval selects the largest servable interval on which every session candle boundary falls
+func sessionSourceInterval(session *store.Session, interval int64, from int64, to int64, resolutions []int64) (int64, error) {
+
+	// boundaries in utc depend on the local clock times, the candle length and the utc offsets of the window
+	g := int64(0)
+	if interval < candlestick.Interval1d {
+		g = interval
+	}
+	for _, t := range session.ClockTimes() {
+		g = gcd(g, t)
+	}
+	for _, t := range []int64{from, to - 1} {
+		_, offset := time.Unix(t, 0).In(session.Location()).Zone()
+		g = gcd(g, int64(offset))
+	}
+	g = gcd(g, candlestick.Interval1d)
+
+	best := int64(0)
+	candidates := append(append(make([]int64, 0), resolutions...), candlestick.IntervalList...)
+	for _, candidate := range candidates {
+		if g%candidate == 0 && candidate > best && servable(candidate, resolutions) {
+			best = candidate
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("%w %d: session boundaries do not align with a primitive resolution", ErrInvalidInterval, interval)
+	}
+	return best, nil
+}
+
+// sessionCandles lists the candles anchored at session opens within [from, to), a daily interval spans a full session
+func sessionCandles(session *store.Session, interval int64, from int64, to int64) []CalendarCandle {
+
+	result := make([]CalendarCandle, 0)
+
+	// walk the local calendar dates overlapping the window, starting a day early for sessions crossing midnight utc
+	day := time.Unix(from, 0).In(session.Location()).AddDate(0, 0, -1)
+	for ; day.Unix() < to+candlestick.Interval1d; day = day.AddDate(0, 0, 1) {
+		open, end, ok := session.Bounds(day.Year(), day.Month(), day.Day())
+		if !ok {
+			continue
+		}
+		step := interval
+		if interval >= candlestick.Interval1d {
+			step = end - open
+		}
+		for t := open; t < end; t += step {
+			if t < from || t >= to {
+				continue
+			}
+			candleEnd := t + step
+			if candleEnd > end {
+				candleEnd = end
+			}
+			result = append(result, CalendarCandle{
+				Candle: candlestick.Candle{Time: t, Missing: true},
+				Start:  t,
+				End:    candleEnd,
+			})
+		}
+	}
+
+	return result
+}
+
+// checkSessionSource rejects blocks which would be built from too many source candles, sessions which only align
+// with fine intervals would otherwise read years of them for daily blocks
+func checkSessionSource(candles []CalendarCandle, source int64, interval int64) error {
+	n := (candles[len(candles)-1].End - candles[0].Start) / source
+	if n > MaxSessionSourceCandles {
+		return fmt.Errorf("%w %d: session candles would be built from %d candles of %ds, at most %d are read", ErrInvalidInterval, interval, n, source, MaxSessionSourceCandles)
+	}
+	return nil
+}
+
+// FetchSessionCandles returns the block of FetchCandles re-anchored on the trading session of the exchange, candles
+// open at session open and stop at session close, so blocks hold a varying number of candles
+func FetchSessionCandles(symbol string, block int64, interval int64, adjust Adjustment, useCache bool) (*candlestick.CandleSet, error) {
+
+	symbolInfo := store.AssetInfo(symbol)
+	if symbolInfo == nil {
+		return nil, ErrSymbolNotFound
+	}
+	exchangeInfo := store.ExchangeInfo(symbolInfo.Identifier.Exchange)
+	if exchangeInfo == nil {
+		return nil, ErrExchangeNotFound
+	}
+	session, err := store.ExchangeSession(symbolInfo.Identifier.Exchange)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrNoSession
+	}
+
+	// candles longer than a day would cross session boundaries
+	if interval <= 0 || interval > candlestick.Interval1d {
+		return nil, fmt.Errorf("%w %d: session candles can not be longer than a day", ErrInvalidInterval, interval)
+	}
+
+	key := getCacheKey(symbol, block, interval, adjust, typeSession)
+	if useCache {
+		if v, ok := candleSetCache.Get(key); ok {
+			return v.(*candlestick.CandleSet), nil
+		}
+	}
+
+	from := candlestick.BlockToUnix(block, interval)
+	to := candlestick.BlockToUnix(block+1, interval)
+
+	source, err := sessionSourceInterval(session, interval, from, to, exchangeInfo.Resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := sessionCandles(session, interval, from, to)
+	if len(candles) == 0 {
+		return nil, ErrBlockNotDownloaded
+	}
+
+	if err = checkSessionSource(candles, source, interval); err != nil {
+		return nil, err
+	}
+
+	data, err := FetchCandleRange(symbol, candles[0].Start, candles[len(candles)-1].End, source, adjust, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	// both lists are in order, so the session candle index only moves forward
+	index := 0
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		for index < len(candles) && c.Time >= candles[index].End {
+			index++
+		}
+		if index == len(candles) {
+			break
+		}
+		if c.Time >= candles[index].Start {
+			mergeCandles(c, &candles[index].Candle)
+		}
+	}
+
+	result := &candlestick.CandleSet{
+		Candles: make([]candlestick.Candle, len(candles)),
+		Meta: candlestick.DataSetMeta{
+			UID:        symbol + ":" + strconv.FormatInt(interval, 10) + ":" + strconv.FormatInt(block, 10) + ":session",
+			Block:      block,
+			Complete:   data.IsComplete(),
+			LastUpdate: data.LastUpdate(),
+			Symbol:     symbol,
+			Interval:   interval,
+		},
+	}
+	for i := range candles {
+		result.Candles[i] = candles[i].Candle
+	}
+
+	if !useCache {
+		return result, nil
+	}
+
+	if !result.IsComplete() {
+		cacheSet(result, adjust, typeSession, 10*time.Second)
+	} else {
+		cacheSet(result, adjust, typeSession, 0)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func TestCheckSessionSource(t *testing.T) {
+
+	// a daily block of sessions opening on the half hour spans 5000 days of 30 minute candles
+	daily := []CalendarCandle{
+		{Start: 0, End: 23400},
+		{Start: 5000 * candlestick.Interval1d, End: 5000*candlestick.Interval1d + 23400},
+	}
+	if err := checkSessionSource(daily, 30*60, candlestick.Interval1d); err != nil {
+		t.Errorf("daily block from 30 minute candles was rejected: %v", err)
+	}
+	if err := checkSessionSource(daily, 5*60, candlestick.Interval1d); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("daily block from 5 minute candles was not rejected: %v", err)
+	}
+}
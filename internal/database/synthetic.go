@@ -0,0 +1,94 @@
+package database
+
+import (
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"strconv"
+	"time"
+)
+
+// combineLegs combines two candles of the same time, the high and low are the widest the combination could have
+// reached given the ranges of both legs since the legs may have peaked at different moments, the combination is
+// missing when either leg is missing or the ratio is undefined, volumes are not combined
+func combineLegs(kind store.SyntheticKind, left *candlestick.Candle, right *candlestick.Candle) candlestick.Candle {
+	c := candlestick.Candle{Time: left.Time}
+	if left.Missing || right.Missing {
+		c.Missing = true
+		return c
+	}
+	switch kind {
+	case store.SyntheticRatio:
+		if right.Low <= 0 {
+			c.Missing = true
+			return c
+		}
+		c.Open = left.Open / right.Open
+		c.High = left.High / right.Low
+		c.Low = left.Low / right.High
+		c.Close = left.Close / right.Close
+	case store.SyntheticSpread:
+		c.Open = left.Open - right.Open
+		c.High = left.High - right.Low
+		c.Low = left.Low - right.High
+		c.Close = left.Close - right.Close
+	}
+	return c
+}
+
+// combineSets combines the same block of both legs, the combination was last updated along with the later leg
+func combineSets(symbol string, kind store.SyntheticKind, left *candlestick.CandleSet, right *candlestick.CandleSet) *candlestick.CandleSet {
+
+	candles := make([]candlestick.Candle, len(left.Candles))
+	for i := range candles {
+		candles[i] = combineLegs(kind, &left.Candles[i], &right.Candles[i])
+	}
+
+	lastUpdate := left.LastUpdate()
+	if right.LastUpdate() > lastUpdate {
+		lastUpdate = right.LastUpdate()
+	}
+	return &candlestick.CandleSet{
+		Candles: candles,
+		Meta: candlestick.DataSetMeta{
+			UID:        symbol + ":" + strconv.FormatInt(left.Interval(), 10) + ":" + strconv.FormatInt(left.BlockNumber(), 10),
+			Block:      left.BlockNumber(),
+			Complete:   left.IsComplete() && right.IsComplete(),
+			LastUpdate: lastUpdate,
+			Symbol:     symbol,
+			Interval:   left.Interval(),
+		},
+	}
+}
+
+// fetchSyntheticCandles builds a block of a synthetic symbol from the same block of both legs
+func fetchSyntheticCandles(symbol string, synthetic *store.Synthetic, block int64, interval int64, adjust Adjustment, useCache bool) (*candlestick.CandleSet, error) {
+
+	key := getCacheKey(symbol, block, interval, adjust, TypeCandles)
+	if useCache {
+		if v, ok := candleSetCache.Get(key); ok {
+			return v.(*candlestick.CandleSet), nil
+		}
+	}
+
+	left, err := FetchCandles(synthetic.Left, block, interval, adjust, useCache)
+	if err != nil {
+		return nil, err
+	}
+	right, err := FetchCandles(synthetic.Right, block, interval, adjust, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	data := combineSets(symbol, synthetic.Kind, left, right)
+	if !useCache {
+		return data, nil
+	}
+
+	if !data.IsComplete() {
+		cacheSet(data, adjust, TypeCandles, 10*time.Second)
+	} else {
+		cacheSet(data, adjust, TypeCandles, 0)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"kio/internal/store"
+	"testing"
+)
+
+func TestCombineLegs(t *testing.T) {
+
+	left := candlestick.Candle{Open: 10, High: 12, Low: 8, Close: 11, Volume: 5}
+	right := candlestick.Candle{Open: 5, High: 8, Low: 4, Close: 4}
+
+	ratio := combineLegs(store.SyntheticRatio, &left, &right)
+	if ratio.Open != 2 || ratio.High != 3 || ratio.Low != 1 || ratio.Close != 2.75 || ratio.Volume != 0 {
+		t.Errorf("unexpected ratio %+v", ratio)
+	}
+
+	spread := combineLegs(store.SyntheticSpread, &left, &right)
+	if spread.Open != 5 || spread.High != 8 || spread.Low != 0 || spread.Close != 7 {
+		t.Errorf("unexpected spread %+v", spread)
+	}
+
+	missing := candlestick.Candle{Missing: true}
+	if c := combineLegs(store.SyntheticSpread, &left, &missing); !c.Missing {
+		t.Errorf("missing leg was combined")
+	}
+
+	zero := candlestick.Candle{Open: 1, High: 1, Low: 0, Close: 1}
+	if c := combineLegs(store.SyntheticRatio, &left, &zero); !c.Missing {
+		t.Errorf("undefined ratio was combined")
+	}
+}
+
+func TestParseSynthetic(t *testing.T) {
+	s, ok := store.ParseSynthetic("SYN:RATIO:BINANCE:SPOT:BTCUSDT:BINANCE:SPOT:ETHUSDT")
+	if !ok || s.Kind != store.SyntheticRatio || s.Left != "BINANCE:SPOT:BTCUSDT" || s.Right != "BINANCE:SPOT:ETHUSDT" {
+		t.Errorf("unexpected synthetic %+v", s)
+	}
+	if _, ok = store.ParseSynthetic("SYN:PRODUCT:BINANCE:SPOT:BTCUSDT:BINANCE:SPOT:ETHUSDT"); ok {
+		t.Errorf("unknown kind was parsed")
+	}
+	if !store.IsSymbolOrLeg("SYN:SPREAD:BINANCE:SPOT:BTCUSDT:BINANCE:SPOT:ETHUSDT", "BINANCE:SPOT:ETHUSDT") {
+		t.Errorf("leg was not recognised")
+	}
+}
+
+func TestCombineSets(t *testing.T) {
+
+	leg := func(symbol string, close float64, complete bool, lastUpdate int64) *candlestick.CandleSet {
+		return &candlestick.CandleSet{
+			Candles: []candlestick.Candle{{Open: close, High: close, Low: close, Close: close, Time: 600}},
+			Meta: candlestick.DataSetMeta{
+				Block: 2, Interval: 300, Symbol: symbol, Complete: complete, LastUpdate: lastUpdate,
+			},
+		}
+	}
+
+	data := combineSets("SYN:SPREAD:A:SPOT:X:B:SPOT:Y", store.SyntheticSpread, leg("A:SPOT:X", 10, true, 500), leg("B:SPOT:Y", 4, false, 700))
+	if data.LastUpdate() != 700 {
+		t.Errorf("expected last update of the later leg, got %d", data.LastUpdate())
+	}
+	if data.IsComplete() || data.BlockNumber() != 2 || data.Interval() != 300 || data.Candles[0].Close != 6 {
+		t.Errorf("unexpected combination %+v", data.Meta)
+	}
+	if data.UID() != "SYN:SPREAD:A:SPOT:X:B:SPOT:Y:300:2" {
+		t.Errorf("unexpected uid %s", data.UID())
+	}
+}
+
+func TestSyntheticHasNoPrimitiveResolution(t *testing.T) {
+	if _, err := smallestResolution("SYN:RATIO:A:SPOT:X:B:SPOT:Y"); !errors.Is(err, store.ErrSyntheticSymbol) {
+		t.Errorf("expected synthetic symbol error, got %v", err)
+	}
+}
@@ -6,28 +6,32 @@ import (
 	"time"
 )
 
-func FetchTransition(symbol string, block int64, interval int64, resolution int64) (*candlestick.CandleSet, error) {
+func FetchTransition(symbol string, block int64, interval int64, resolution int64, adjust Adjustment) (*candlestick.CandleSet, error) {
 
 	// Skip candling when interval is 1 minute
 	if interval == resolution {
-		return primitiveSet(symbol, block, interval)
+		s, err := primitiveSet(symbol, block, interval, adjust)
+		if err == nil && s == nil {
+			return nil, ErrBlockNotDownloaded
+		}
+		return s, err
 	}
 
 	// fetch last minute candles to generate first candle on
-	lastBlock, err := primitiveSet(symbol, block-1, resolution)
+	lastBlock, err := primitiveSet(symbol, block-1, resolution, adjust)
 	if err != nil {
 		return nil, err
 	}
 
 	// fetch current minute candles
-	currBlock, err := primitiveSet(symbol, block, resolution)
+	currBlock, err := primitiveSet(symbol, block, resolution, adjust)
 	if err != nil {
 		return nil, err
 	}
 
 	// check if current set exists
 	if currBlock == nil {
-		return nil, nil
+		return nil, ErrBlockNotDownloaded
 	}
 
 	// generate first part of first candle
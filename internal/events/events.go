@@ -0,0 +1,97 @@
+package events
+
+import (
+	"sync"
+)
+
+const (
+	BlockWritten   = "block-written"
+	BlockCompleted = "block-completed"
+	AuditCompleted = "audit-completed"
+)
+
+// number of events kept in memory for clients resuming a feed
+const bufferSize = 4096
+
+// number of events that can be queued for a subscriber before it is dropped
+const subscriberBuffer = 256
+
+type Event struct {
+	ID         uint64 `json:"id"`
+	Type       string `json:"type"`
+	Symbol     string `json:"symbol,omitempty"`
+	Interval   int64  `json:"interval,omitempty"`
+	Block      int64  `json:"block"`
+	LastUpdate int64  `json:"lastUpdate"`
+}
+
+var ring = make([]Event, bufferSize)
+var lastID = uint64(0)
+var subscribers = make(map[chan Event]bool)
+var lock = sync.Mutex{}
+
+// Publish stores an event in the ring buffer and forwards it to all subscribers
+func Publish(kind string, symbol string, interval int64, block int64, lastUpdate int64) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	lastID++
+	e := Event{
+		ID:         lastID,
+		Type:       kind,
+		Symbol:     symbol,
+		Interval:   interval,
+		Block:      block,
+		LastUpdate: lastUpdate,
+	}
+	ring[lastID%bufferSize] = e
+
+	// drop subscribers that can't keep up, they can resume from the ring buffer
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns all buffered events after the given id and a channel receiving new events
+func Subscribe(after uint64) ([]Event, chan Event) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	// clamp to the oldest event still held in the buffer
+	first := after + 1
+	if lastID >= bufferSize && first <= lastID-bufferSize {
+		first = lastID - bufferSize + 1
+	}
+	backlog := make([]Event, 0)
+	for id := first; id <= lastID; id++ {
+		backlog = append(backlog, ring[id%bufferSize])
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	subscribers[ch] = true
+	return backlog, ch
+}
+
+// SubscribeNew returns a channel receiving the events published from now on, without any buffered events
+func SubscribeNew() chan Event {
+	lock.Lock()
+	defer lock.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	subscribers[ch] = true
+	return ch
+}
+
+// Unsubscribe stops delivery of events to the channel
+func Unsubscribe(ch chan Event) {
+	lock.Lock()
+	defer lock.Unlock()
+	if subscribers[ch] {
+		delete(subscribers, ch)
+		close(ch)
+	}
+}
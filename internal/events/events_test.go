@@ -0,0 +1,31 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestSubscribeReplaysOnlyAfterLastEvent(t *testing.T) {
+
+	Publish(BlockWritten, "TEST:SPOT:A", 60, 1, 0)
+	Publish(BlockWritten, "TEST:SPOT:A", 60, 2, 0)
+	last := lastID
+
+	backlog, ch := Subscribe(last - 1)
+	defer Unsubscribe(ch)
+	if len(backlog) != 1 || backlog[0].Block != 2 {
+		t.Fatalf("unexpected backlog %+v", backlog)
+	}
+
+	fresh := SubscribeNew()
+	defer Unsubscribe(fresh)
+	if len(fresh) != 0 {
+		t.Fatal("new subscriber received buffered events")
+	}
+	Publish(BlockCompleted, "TEST:SPOT:A", 60, 2, 0)
+	if e := <-fresh; e.ID != last+1 || e.Type != BlockCompleted {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := <-ch; e.ID != last+1 {
+		t.Errorf("resumed subscriber missed event %+v", e)
+	}
+}
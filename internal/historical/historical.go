@@ -3,6 +3,8 @@ package historical
 import (
 	"github.com/godoji/candlestick"
 	"kio/internal/config"
+	"kio/internal/events"
+	"kio/internal/metrics"
 	"kio/internal/store"
 	"log"
 	"sync"
@@ -23,6 +25,7 @@ func RunHistoryAuditService() (chan interface{}, chan interface{}) {
 		if config.ServiceConfig().HasAudit() {
 			fetchPastBlocks(stop)
 		} else {
+			historyBusy = false
 			<-stop
 		}
 		done <- nil
@@ -65,7 +68,10 @@ func fetchBlocksForSymbol(symbol string, startTime int64, interval int64, isAbor
 		}
 
 		// download the block which returns the next block in line
-		i = store.DownloadBlocksToDisk(i, symbol, interval)
+		i, err = store.DownloadBlocksToDisk(i, symbol, interval)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	elapsed := time.Now().Unix() - timerStart
@@ -92,6 +98,15 @@ func fetchPastBlocks(stop chan interface{}) {
 
 	cancel := false
 
+	// the task gauges describe the running audit only
+	tasks := 0
+	for _, exchange := range info.Exchanges {
+		tasks += len(exchange.Symbols) * len(exchange.Resolution)
+	}
+	metrics.AuditBusy.Set(1)
+	metrics.AuditTasks.Set(float64(tasks))
+	metrics.AuditTasksCompleted.Set(0)
+
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 8)
 	for _, exchange := range info.Exchanges {
@@ -107,6 +122,7 @@ func fetchPastBlocks(stop chan interface{}) {
 					}
 					startTime := meta.OnBoardDate
 					fetchBlocksForSymbol(symbol, startTime, interval, &cancel, stop)
+					metrics.AuditTasksCompleted.Inc()
 					<-sem
 				}(symbol, exchange, interval)
 			}
@@ -115,7 +131,9 @@ func fetchPastBlocks(stop chan interface{}) {
 	wg.Wait()
 
 	historyBusy = false
+	metrics.AuditBusy.Set(0)
 	log.Println("database audit completed")
+	events.Publish(events.AuditCompleted, "", 0, 0, time.Now().UTC().Unix())
 
 	if !cancel {
 		<-stop
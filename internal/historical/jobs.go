@@ -0,0 +1,200 @@
+package historical
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"kio/internal/database"
+	"kio/internal/store"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    = JobStatus("queued")
+	JobRunning   = JobStatus("running")
+	JobCompleted = JobStatus("completed")
+	JobFailed    = JobStatus("failed")
+)
+
+// number of finished jobs kept in memory for status queries
+const maxJobHistory = 256
+
+// number of jobs that can wait for the worker
+const jobQueueSize = 64
+
+var ErrJobQueueFull = errors.New("re-download queue is full")
+
+type Job struct {
+	ID          string    `json:"id"`
+	Symbol      string    `json:"symbol"`
+	Interval    int64     `json:"interval"`
+	FromBlock   int64     `json:"fromBlock"`
+	ToBlock     int64     `json:"toBlock"`
+	Status      JobStatus `json:"status"`
+	BlocksTotal int64     `json:"blocksTotal"`
+	BlocksDone  int64     `json:"blocksDone"`
+	Errors      []string  `json:"errors"`
+	CreatedAt   int64     `json:"createdAt"`
+	StartedAt   int64     `json:"startedAt,omitempty"`
+	FinishedAt  int64     `json:"finishedAt,omitempty"`
+}
+
+var jobs = make(map[string]*Job)
+var jobsLock = sync.Mutex{}
+var jobQueue = make(chan *Job, jobQueueSize)
+var jobWorkerOnce = sync.Once{}
+
+// EnqueueRedownload schedules a download of the block range [fromBlock, toBlock], also for blocks marked complete
+func EnqueueRedownload(symbol string, interval int64, fromBlock int64, toBlock int64) (Job, error) {
+
+	jobWorkerOnce.Do(func() {
+		go runJobWorker()
+	})
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:          hex.EncodeToString(id),
+		Symbol:      symbol,
+		Interval:    interval,
+		FromBlock:   fromBlock,
+		ToBlock:     toBlock,
+		Status:      JobQueued,
+		BlocksTotal: toBlock - fromBlock + 1,
+		Errors:      make([]string, 0),
+		CreatedAt:   time.Now().UTC().Unix(),
+	}
+
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+
+	select {
+	case jobQueue <- job:
+	default:
+		return Job{}, ErrJobQueueFull
+	}
+	jobs[job.ID] = job
+	pruneJobs()
+
+	return copyJob(job), nil
+}
+
+// JobInfo returns a snapshot of a job
+func JobInfo(id string) (Job, bool) {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	job, ok := jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return copyJob(job), true
+}
+
+// JobList returns snapshots of all known jobs, most recent first
+func JobList() []Job {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	result := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, copyJob(job))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt > result[j].CreatedAt
+	})
+	return result
+}
+
+func copyJob(job *Job) Job {
+	c := *job
+	c.Errors = append(make([]string, 0, len(job.Errors)), job.Errors...)
+	return c
+}
+
+// pruneJobs removes the oldest finished jobs when the history grows too large, requires jobsLock
+func pruneJobs() {
+	if len(jobs) <= maxJobHistory {
+		return
+	}
+	finished := make([]*Job, 0)
+	for _, job := range jobs {
+		if job.Status == JobCompleted || job.Status == JobFailed {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt < finished[j].FinishedAt
+	})
+	for i := 0; i < len(finished) && len(jobs) > maxJobHistory; i++ {
+		delete(jobs, finished[i].ID)
+	}
+}
+
+func runJobWorker() {
+	for job := range jobQueue {
+		runJob(job)
+	}
+}
+
+func runJob(job *Job) {
+
+	jobsLock.Lock()
+	job.Status = JobRunning
+	job.StartedAt = time.Now().UTC().Unix()
+	jobsLock.Unlock()
+
+	log.Printf("re-download job %s started for %s blocks %d-%d (%d)\n", job.ID, job.Symbol, job.FromBlock, job.ToBlock, job.Interval)
+
+	// downloads can write blocks past the end of the range
+	lastWritten := job.ToBlock
+
+	defer func() {
+		// drop cached sets built from the previous block contents
+		database.PurgeBlocks(job.Symbol, job.Interval, job.FromBlock, lastWritten)
+
+		// downloads can panic on malformed bridge responses, which should only fail the job
+		if r := recover(); r != nil {
+			jobsLock.Lock()
+			job.Errors = append(job.Errors, fmt.Sprintf("aborted: %v", r))
+			jobsLock.Unlock()
+		}
+		jobsLock.Lock()
+		job.FinishedAt = time.Now().UTC().Unix()
+		if len(job.Errors) == 0 {
+			job.Status = JobCompleted
+		} else {
+			job.Status = JobFailed
+		}
+		jobsLock.Unlock()
+		log.Printf("re-download job %s finished with %d errors\n", job.ID, len(job.Errors))
+	}()
+
+	for block := job.FromBlock; block <= job.ToBlock; block++ {
+
+		// download the block which returns the last block that was written
+		last, err := store.DownloadBlocksToDisk(block, job.Symbol, job.Interval)
+
+		jobsLock.Lock()
+		if err != nil {
+			job.Errors = append(job.Errors, fmt.Sprintf("block %d: %s", block, err.Error()))
+		} else if last > block {
+			block = last
+		}
+		if block > lastWritten {
+			lastWritten = block
+		}
+		job.BlocksDone = block - job.FromBlock + 1
+		if job.BlocksDone > job.BlocksTotal {
+			job.BlocksDone = job.BlocksTotal
+		}
+		jobsLock.Unlock()
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"github.com/dgraph-io/ristretto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "kio"
+
+var (
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled http requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HttpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled http requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	DiskReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "disk_reads_total",
+		Help:      "Number of block reads from disk by result (hit, miss, error).",
+	}, []string{"result"})
+
+	DiskReadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "disk_read_duration_seconds",
+		Help:      "Latency of reading and decoding a block from disk.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	DiskWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "disk_writes_total",
+		Help:      "Number of block writes to disk by result (ok, error).",
+	}, []string{"result"})
+
+	DiskWriteDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "disk_write_duration_seconds",
+		Help:      "Latency of encoding and writing a block to disk.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	BridgeRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bridge_requests_total",
+		Help:      "Number of requests sent to the api bridge by endpoint and result (ok, error).",
+	}, []string{"endpoint", "result"})
+
+	BridgeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bridge_request_duration_seconds",
+		Help:      "Latency of requests sent to the api bridge by endpoint.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"endpoint"})
+
+	AuditBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "audit_busy",
+		Help:      "Whether the historical audit is running.",
+	})
+
+	AuditTasks = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "audit_tasks",
+		Help:      "Number of symbol and interval pairs the historical audit has to verify.",
+	})
+
+	AuditTasksCompleted = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "audit_tasks_completed",
+		Help:      "Number of symbol and interval pairs the historical audit has verified.",
+	})
+)
+
+// RegisterCache exposes the metrics of a ristretto cache, the cache must be created with metrics enabled
+func RegisterCache(name string, m *ristretto.Metrics) {
+
+	labels := prometheus.Labels{"cache": name}
+	counter := func(metric string, help string, f func() uint64) {
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        metric,
+			Help:        help,
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(f())
+		})
+	}
+
+	counter("cache_hits_total", "Number of cache hits.", m.Hits)
+	counter("cache_misses_total", "Number of cache misses.", m.Misses)
+	counter("cache_keys_added_total", "Number of keys added to the cache.", m.KeysAdded)
+	counter("cache_keys_evicted_total", "Number of keys evicted from the cache.", m.KeysEvicted)
+	counter("cache_cost_added_total", "Total cost added to the cache.", m.CostAdded)
+	counter("cache_cost_evicted_total", "Total cost evicted from the cache.", m.CostEvicted)
+	counter("cache_sets_rejected_total", "Number of sets rejected by the cache policy.", m.SetsRejected)
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "cache_hit_ratio",
+		Help:        "Ratio of cache hits over all lookups.",
+		ConstLabels: labels,
+	}, m.Ratio)
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kio/internal/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const actionsFile = "actions.json"
+
+type ActionType string
+
+const (
+	ActionDividend     = ActionType("dividend")
+	ActionSplit        = ActionType("split")
+	ActionSymbolChange = ActionType("symbol-change")
+)
+
+var ErrInvalidAction = errors.New("invalid corporate action")
+
+// Action is a corporate action of a symbol taking effect at Time, symbol changes are recorded but do not
+// change prices
+type Action struct {
+	ID         string     `json:"id"`
+	Type       ActionType `json:"type"`
+	Time       int64      `json:"time"`
+	Amount     float64    `json:"amount,omitempty"`
+	Ratio      float64    `json:"ratio,omitempty"`
+	FromSymbol string     `json:"fromSymbol,omitempty"`
+	ToSymbol   string     `json:"toSymbol,omitempty"`
+}
+
+var actionsCache map[string][]Action = nil
+var actionsCacheLock = sync.Mutex{}
+
+func actionsPath() string {
+	return filepath.Join(config.ServiceConfig().DataDir(), actionsFile)
+}
+
+// validate checks the fields required by the type of the action
+func (a *Action) validate() error {
+	switch a.Type {
+	case ActionDividend:
+		if a.Amount <= 0 {
+			return fmt.Errorf("%w: dividend requires a positive amount", ErrInvalidAction)
+		}
+	case ActionSplit:
+		if a.Ratio <= 0 {
+			return fmt.Errorf("%w: split requires a positive ratio", ErrInvalidAction)
+		}
+	case ActionSymbolChange:
+		if a.FromSymbol == "" && a.ToSymbol == "" {
+			return fmt.Errorf("%w: symbol change requires fromSymbol or toSymbol", ErrInvalidAction)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAction, a.Type)
+	}
+	return nil
+}
+
+// loadActions reads the actions store, requires actionsCacheLock
+func loadActions() error {
+	if actionsCache != nil {
+		return nil
+	}
+	actions := make(map[string][]Action)
+	file, err := os.Open(actionsPath())
+	if os.IsNotExist(err) {
+		actionsCache = actions
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(&actions); err != nil {
+		return fmt.Errorf("invalid %s: %w", actionsFile, err)
+	}
+	actionsCache = actions
+	return nil
+}
+
+// saveActions replaces the actions store on disk, requires actionsCacheLock
+func saveActions() error {
+	tmp := actionsPath() + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(actionsCache); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, actionsPath())
+}
+
+// SymbolActions returns the corporate actions of a symbol ordered by time
+func SymbolActions(symbol string) ([]Action, error) {
+	actionsCacheLock.Lock()
+	defer actionsCacheLock.Unlock()
+	if err := loadActions(); err != nil {
+		return nil, err
+	}
+	return append(make([]Action, 0, len(actionsCache[symbol])), actionsCache[symbol]...), nil
+}
+
+// AddAction validates and stores a corporate action of a symbol, returning it with its assigned id
+func AddAction(symbol string, action Action) (Action, error) {
+
+	if err := action.validate(); err != nil {
+		return action, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return action, err
+	}
+	action.ID = hex.EncodeToString(id)
+
+	actionsCacheLock.Lock()
+	defer actionsCacheLock.Unlock()
+	if err := loadActions(); err != nil {
+		return action, err
+	}
+
+	previous := actionsCache[symbol]
+	actions := append(append(make([]Action, 0, len(previous)+1), previous...), action)
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Time < actions[j].Time
+	})
+	actionsCache[symbol] = actions
+	if err := saveActions(); err != nil {
+		actionsCache[symbol] = previous
+		return action, err
+	}
+	return action, nil
+}
+
+// DeleteAction removes a corporate action of a symbol and reports whether it existed
+func DeleteAction(symbol string, id string) (bool, error) {
+
+	actionsCacheLock.Lock()
+	defer actionsCacheLock.Unlock()
+	if err := loadActions(); err != nil {
+		return false, err
+	}
+
+	previous := actionsCache[symbol]
+	actions := make([]Action, 0, len(previous))
+	for _, action := range previous {
+		if action.ID != id {
+			actions = append(actions, action)
+		}
+	}
+	if len(actions) == len(previous) {
+		return false, nil
+	}
+
+	if len(actions) == 0 {
+		delete(actionsCache, symbol)
+	} else {
+		actionsCache[symbol] = actions
+	}
+	if err := saveActions(); err != nil {
+		actionsCache[symbol] = previous
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/godoji/candlestick"
+	"kio/internal/config"
+	"sort"
+	"sync"
+)
+
+var ErrNoConversion = errors.New("no conversion path found")
+
+// ConversionStep converts a price through the candles of a symbol, multiplying by its price to go from its base
+// to its quote asset or dividing by it when Inverse is set
+type ConversionStep struct {
+	Symbol  string `json:"symbol"`
+	Inverse bool   `json:"inverse"`
+}
+
+// String writes the symbol of a step, prefixed with 1/ when its price divides
+func (s ConversionStep) String() string {
+	if s.Inverse {
+		return "1/" + s.Symbol
+	}
+	return s.Symbol
+}
+
+type conversionEdge struct {
+	asset string
+	step  ConversionStep
+}
+
+// conversionGraph maps every asset to the assets its prices convert into in a single step
+type conversionGraph map[string][]conversionEdge
+
+var conversionGraphCache conversionGraph = nil
+var conversionGraphSource *candlestick.ExchangeList = nil
+var conversionGraphLock = sync.Mutex{}
+
+// newConversionGraph links the base and quote assets of the symbols, ordered by symbol so the same path is
+// returned every time
+func newConversionGraph(symbols []*candlestick.AssetInfo) conversionGraph {
+
+	sorted := append(make([]*candlestick.AssetInfo, 0, len(symbols)), symbols...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Identifier.ToString() < sorted[j].Identifier.ToString()
+	})
+
+	edges := make(conversionGraph)
+	for _, info := range sorted {
+		if info.BaseAsset == "" || info.QuoteAsset == "" || info.BaseAsset == info.QuoteAsset {
+			continue
+		}
+		symbol := info.Identifier.ToString()
+		edges[info.BaseAsset] = append(edges[info.BaseAsset], conversionEdge{info.QuoteAsset, ConversionStep{Symbol: symbol}})
+		edges[info.QuoteAsset] = append(edges[info.QuoteAsset], conversionEdge{info.BaseAsset, ConversionStep{Symbol: symbol, Inverse: true}})
+	}
+	return edges
+}
+
+// path returns the shortest chain of symbols converting prices quoted in one asset into another
+func (g conversionGraph) path(from string, to string) ([]ConversionStep, error) {
+
+	// breadth first search from the source asset, remembering how every asset was reached
+	reached := map[string]*conversionEdge{from: nil}
+	previous := make(map[string]string)
+	queue := []string{from}
+	for len(queue) > 0 && reached[to] == nil && from != to {
+		asset := queue[0]
+		queue = queue[1:]
+		for i := range g[asset] {
+			e := &g[asset][i]
+			if _, ok := reached[e.asset]; ok {
+				continue
+			}
+			reached[e.asset] = e
+			previous[e.asset] = asset
+			queue = append(queue, e.asset)
+		}
+	}
+
+	if _, ok := reached[to]; !ok {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoConversion, from, to)
+	}
+
+	path := make([]ConversionStep, 0)
+	for asset := to; asset != from; asset = previous[asset] {
+		path = append([]ConversionStep{reached[asset].step}, path...)
+	}
+	return path, nil
+}
+
+// FindConversionPath returns the shortest chain of symbols converting prices quoted in one asset into another,
+// ties are broken by the order of the symbols so the same path is returned every time
+func FindConversionPath(from string, to string, symbols []*candlestick.AssetInfo) ([]ConversionStep, error) {
+	return newConversionGraph(symbols).path(from, to)
+}
+
+// ConversionPath resolves the conversion of prices between two assets through the configured conversion
+// symbols, or through every symbol of the market with data on disk when none are configured. The graph is
+// built once per market info, so symbols downloaded since are only considered after the market info refreshes
+func ConversionPath(from string, to string) ([]ConversionStep, error) {
+
+	info, err := MarketInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	conversionGraphLock.Lock()
+	defer conversionGraphLock.Unlock()
+
+	if conversionGraphCache == nil || conversionGraphSource != info {
+		symbols := make([]*candlestick.AssetInfo, 0)
+		if configured := config.ServiceConfig().ConversionSymbols(); len(configured) > 0 {
+			for _, symbol := range configured {
+				if asset := AssetInfo(symbol); asset != nil {
+					symbols = append(symbols, asset)
+				}
+			}
+		} else {
+			for _, exchange := range info.Exchanges {
+				for symbol, asset := range exchange.Symbols {
+					if HasDiskData(symbol) {
+						symbols = append(symbols, asset)
+					}
+				}
+			}
+		}
+		conversionGraphCache = newConversionGraph(symbols)
+		conversionGraphSource = info
+	}
+
+	return conversionGraphCache.path(from, to)
+}
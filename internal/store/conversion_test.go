@@ -0,0 +1,37 @@
+package store
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func asset(symbol string, base string, quote string) *candlestick.AssetInfo {
+	identifier, _ := candlestick.ParseSymbol(symbol)
+	return &candlestick.AssetInfo{Identifier: identifier, BaseAsset: base, QuoteAsset: quote}
+}
+
+func TestFindConversionPath(t *testing.T) {
+
+	symbols := []*candlestick.AssetInfo{
+		asset("BINANCE:SPOT:ETHBTC", "ETH", "BTC"),
+		asset("BINANCE:SPOT:BTCUSDT", "BTC", "USDT"),
+		asset("KRAKEN:SPOT:EURUSDT", "EUR", "USDT"),
+	}
+
+	path, err := FindConversionPath("BTC", "EUR", symbols)
+	if err != nil || len(path) != 2 {
+		t.Fatalf("unexpected path %+v %v", path, err)
+	}
+	if path[0] != (ConversionStep{Symbol: "BINANCE:SPOT:BTCUSDT"}) || path[1] != (ConversionStep{Symbol: "KRAKEN:SPOT:EURUSDT", Inverse: true}) {
+		t.Errorf("unexpected path %+v", path)
+	}
+
+	if path, err = FindConversionPath("USDT", "USDT", symbols); err != nil || len(path) != 0 {
+		t.Errorf("conversion into the same asset is not empty: %+v %v", path, err)
+	}
+
+	if _, err = FindConversionPath("USDT", "JPY", symbols); !errors.Is(err, ErrNoConversion) {
+		t.Errorf("unreachable asset was converted")
+	}
+}
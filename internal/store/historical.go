@@ -3,16 +3,21 @@ package store
 import (
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/godoji/candlestick"
 	"io"
 	"kio/internal/config"
+	"kio/internal/events"
+	"kio/internal/metrics"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,19 +31,42 @@ func blockDiskPath(symbol string, block int64, resolution int64) (string, string
 	return dir, file, meta
 }
 
-func DownloadBlocksToDisk(blockNumber int64, symbol string, interval int64) int64 {
+// ErrBadCandleSequence is returned when downloaded candles do not line up with the blocks they belong to
+var ErrBadCandleSequence = errors.New("bad candle sequence detected")
+
+// seriesLocks serializes the downloads of a symbol and interval, the startup audit and re-download jobs would
+// otherwise truncate each other's block files
+var seriesLocks = make(map[string]*sync.Mutex)
+var seriesLocksLock = sync.Mutex{}
+
+func seriesLock(symbol string, interval int64) *sync.Mutex {
+	seriesLocksLock.Lock()
+	defer seriesLocksLock.Unlock()
+	key := fmt.Sprintf("%s/%d", symbol, interval)
+	lock, ok := seriesLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		seriesLocks[key] = lock
+	}
+	return lock
+}
+
+func DownloadBlocksToDisk(blockNumber int64, symbol string, interval int64) (int64, error) {
+	lock := seriesLock(symbol, interval)
+	lock.Lock()
+	defer lock.Unlock()
 	data, lastBlock, err := DownloadBlocks(blockNumber, symbol, interval)
 	if err != nil {
-		log.Fatal(err)
+		return blockNumber, err
 	}
 	for _, b := range data {
 		err = WriteToDisk(b)
 		if err != nil {
 			log.Printf("failed writing %s block %d to disk\n", b.Symbol(), b.BlockNumber())
-			log.Fatal(err)
+			return blockNumber, err
 		}
 	}
-	return lastBlock
+	return lastBlock, nil
 }
 
 func DownloadBlocks(blockNumber int64, symbol string, interval int64) (map[int64]*candlestick.CandleSet, int64, error) {
@@ -87,7 +115,7 @@ func DownloadBlocks(blockNumber int64, symbol string, interval int64) (map[int64
 				t += interval
 				addedCandles++
 				if addedCandles > candlestick.CandleSetSize {
-					log.Fatalf("bad candle sequence detected: %s\n", symbol)
+					return nil, 0, fmt.Errorf("%w: %s block %d", ErrBadCandleSequence, symbol, candleBlock)
 				}
 			}
 			candleSets[candleBlock] = set
@@ -114,7 +142,7 @@ func DownloadBlocks(blockNumber int64, symbol string, interval int64) (map[int64
 			t += interval
 			addedCandles++
 			if addedCandles > candlestick.CandleSetSize {
-				log.Fatalf("bad candle sequence detected: %s\n", symbol)
+				return nil, 0, fmt.Errorf("%w: %s block %d", ErrBadCandleSequence, symbol, set.BlockNumber())
 			}
 		}
 	}
@@ -126,8 +154,27 @@ func DownloadBlocks(blockNumber int64, symbol string, interval int64) (map[int64
 }
 
 func WriteToDisk(data *candlestick.CandleSet) error {
+	timerStart := time.Now()
+	err := writeToDisk(data)
+	metrics.DiskWriteDuration.Observe(time.Since(timerStart).Seconds())
+	if err != nil {
+		metrics.DiskWrites.WithLabelValues("error").Inc()
+	} else {
+		metrics.DiskWrites.WithLabelValues("ok").Inc()
+	}
+	return err
+}
+
+func writeToDisk(data *candlestick.CandleSet) error {
+
+	// keep previous state to detect blocks turning complete
+	previous, err := BlockMeta(data.Symbol(), data.BlockNumber(), data.Interval())
+	if err != nil {
+		log.Println(err)
+	}
+
 	dir, dst, fMeta := blockDiskPath(data.Symbol(), data.BlockNumber(), data.Interval())
-	err := os.MkdirAll(dir, 0755)
+	err = os.MkdirAll(dir, 0755)
 	if err != nil {
 		return err
 	}
@@ -159,6 +206,11 @@ func WriteToDisk(data *candlestick.CandleSet) error {
 		log.Println(err)
 	}
 
+	events.Publish(events.BlockWritten, data.Symbol(), data.Interval(), data.BlockNumber(), data.LastUpdate())
+	if data.IsComplete() && (previous == nil || !previous.Complete) {
+		events.Publish(events.BlockCompleted, data.Symbol(), data.Interval(), data.BlockNumber(), data.LastUpdate())
+	}
+
 	// log.Printf("wrote %s block %d (%d) to disk\n", data.Symbol(), data.BlockNumber(), data.Interval())
 	return nil
 }
@@ -225,7 +277,58 @@ func OldestBlock(symbol string) (int64, bool) {
 	return blockLowerBound, true
 }
 
+// DiskBlocks lists the blocks of a symbol and interval that are stored on disk in ascending order
+func DiskBlocks(symbol string, interval int64) ([]int64, error) {
+	dir := fmt.Sprintf("%s/db/%s/%d", config.ServiceConfig().DataDir(), symbol, interval)
+	blocks := make([]int64, 0)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return blocks, nil
+	}
+	err := filepath.Walk(dir,
+		func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(filePath, ".bin") {
+				return nil
+			}
+			blockNumber, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(filePath), ".bin"), 10, 64)
+			if err != nil {
+				return nil
+			}
+			blocks = append(blocks, blockNumber-blockDiskOffset)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i] < blocks[j]
+	})
+	return blocks, nil
+}
+
+// HasDiskData reports whether any block of a symbol was downloaded
+func HasDiskData(symbol string) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/db/%s", config.ServiceConfig().DataDir(), symbol))
+	return err == nil
+}
+
 func LoadFromDisk(symbol string, block int64, resolution int64) (*candlestick.CandleSet, error) {
+	timerStart := time.Now()
+	data, err := loadFromDisk(symbol, block, resolution)
+	if err != nil {
+		metrics.DiskReads.WithLabelValues("error").Inc()
+	} else if data == nil {
+		metrics.DiskReads.WithLabelValues("miss").Inc()
+	} else {
+		metrics.DiskReads.WithLabelValues("hit").Inc()
+		metrics.DiskReadDuration.Observe(time.Since(timerStart).Seconds())
+	}
+	return data, err
+}
+
+func loadFromDisk(symbol string, block int64, resolution int64) (*candlestick.CandleSet, error) {
 
 	_, fName, _ := blockDiskPath(symbol, block, resolution)
 
@@ -1,12 +1,14 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/godoji/candlestick"
 	"io"
 	"kio/internal/config"
+	"kio/internal/metrics"
 	"log"
 	"math"
 	"net/http"
@@ -20,6 +22,9 @@ var marketInfoCacheLock = sync.Mutex{}
 var symbolsCache []string = nil
 var symbolsCacheLock = sync.Mutex{}
 
+// marketInfoClient bounds market info requests, which hold the market info lock while waiting
+var marketInfoClient = &http.Client{Timeout: 30 * time.Second}
+
 var (
 	ErrCandleServiceUnavailable = errors.New("candle provider encountered an error")
 )
@@ -73,6 +78,9 @@ func ExchangeInfo(exchangeId string) *candlestick.ExchangeInfo {
 }
 
 func AssetInfo(symbol string) *candlestick.AssetInfo {
+	if s, ok := ParseSynthetic(symbol); ok {
+		return syntheticAssetInfo(symbol, s)
+	}
 	identifier, ok := candlestick.ParseSymbol(symbol)
 	if !ok {
 		return nil
@@ -93,6 +101,29 @@ func AssetInfo(symbol string) *candlestick.AssetInfo {
 	return nil
 }
 
+// PingBridge requests the market info of the bridge without decoding it, failing once the context is done
+func PingBridge(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/market/info", config.ServiceConfig().DataBridgeURL()), nil)
+	if err != nil {
+		return err
+	}
+	timerStart := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	metrics.BridgeDuration.WithLabelValues("ping").Observe(time.Since(timerStart).Seconds())
+	if err != nil {
+		metrics.BridgeRequests.WithLabelValues("ping", "error").Inc()
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		metrics.BridgeRequests.WithLabelValues("ping", "error").Inc()
+		return fmt.Errorf("%w: status %d", ErrCandleServiceUnavailable, resp.StatusCode)
+	}
+	metrics.BridgeRequests.WithLabelValues("ping", "ok").Inc()
+	return nil
+}
+
 func MarketInfo() (*candlestick.ExchangeList, error) {
 
 	marketInfoCacheLock.Lock()
@@ -101,8 +132,11 @@ func MarketInfo() (*candlestick.ExchangeList, error) {
 	if marketInfoCache == nil {
 
 		// fetch
-		resp, err := http.Get(fmt.Sprintf("%s/market/info", config.ServiceConfig().DataBridgeURL()))
+		timerStart := time.Now()
+		resp, err := marketInfoClient.Get(fmt.Sprintf("%s/market/info", config.ServiceConfig().DataBridgeURL()))
+		metrics.BridgeDuration.WithLabelValues("info").Observe(time.Since(timerStart).Seconds())
 		if err != nil {
+			metrics.BridgeRequests.WithLabelValues("info", "error").Inc()
 			return nil, err
 		}
 
@@ -121,8 +155,10 @@ func MarketInfo() (*candlestick.ExchangeList, error) {
 		}
 
 		if err != nil {
+			metrics.BridgeRequests.WithLabelValues("info", "error").Inc()
 			return nil, err
 		}
+		metrics.BridgeRequests.WithLabelValues("info", "ok").Inc()
 
 		log.Printf("retrieved exchange info containing %d exchanges\n", len(result.Exchanges))
 
@@ -180,7 +216,7 @@ func fetchAlignedCandles(block int64, symbol string, interval int64) ([]candlest
 		// make sure we don't send requests forever
 		attempts++
 		if attempts > 20 {
-			log.Fatalf("too many attempts fetching %s till %d\n", symbol, blockEndTime)
+			return nil, fmt.Errorf("too many attempts fetching %s till %d", symbol, blockEndTime)
 		}
 	}
 
@@ -192,13 +228,16 @@ func requestCandlesAligned(from int64, symbol string, interval int64) ([]candles
 	timerStart := time.Now().UTC().UnixMilli()
 	resp, err := http.Get(fmt.Sprintf("%s/market/%s/historical?interval=%d&from=%d", config.ServiceConfig().DataBridgeURL(), symbol, interval, from))
 	elapsed := time.Now().UTC().UnixMilli() - timerStart
+	metrics.BridgeDuration.WithLabelValues("historical").Observe(float64(elapsed) / 1000)
 
 	// check request error
 	if err != nil {
+		metrics.BridgeRequests.WithLabelValues("historical", "error").Inc()
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		metrics.BridgeRequests.WithLabelValues("historical", "error").Inc()
 		message := ""
 		if resp.Body != nil {
 			raw, _ := io.ReadAll(resp.Body)
@@ -224,8 +263,10 @@ func requestCandlesAligned(from int64, symbol string, interval int64) ([]candles
 
 	// check decode errors
 	if err != nil {
+		metrics.BridgeRequests.WithLabelValues("historical", "error").Inc()
 		return nil, err
 	}
+	metrics.BridgeRequests.WithLabelValues("historical", "ok").Inc()
 
 	// make sure this request always takes a minimum of 3s
 	// TODO: make rate limiting more reliable
@@ -244,13 +285,17 @@ func requestCandlesAligned(from int64, symbol string, interval int64) ([]candles
 func RequestCandlesFromTime(startTime int64, symbol string) ([]candlestick.Candle, error) {
 
 	// send request
+	timerStart := time.Now()
 	resp, err := http.Get(fmt.Sprintf("%s/market/%s/latest?from=%d", config.ServiceConfig().DataBridgeURL(), symbol, startTime))
+	metrics.BridgeDuration.WithLabelValues("latest").Observe(time.Since(timerStart).Seconds())
 	if err != nil {
+		metrics.BridgeRequests.WithLabelValues("latest", "error").Inc()
 		return nil, err
 	}
 
 	// check status code
 	if resp.StatusCode != http.StatusOK {
+		metrics.BridgeRequests.WithLabelValues("latest", "error").Inc()
 		return nil, ErrCandleServiceUnavailable
 	}
 
@@ -270,8 +315,10 @@ func RequestCandlesFromTime(startTime int64, symbol string) ([]candlestick.Candl
 
 	// check decode errors
 	if err != nil {
+		metrics.BridgeRequests.WithLabelValues("latest", "error").Inc()
 		return nil, err
 	}
+	metrics.BridgeRequests.WithLabelValues("latest", "ok").Inc()
 
 	return result.Candles, nil
 
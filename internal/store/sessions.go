@@ -0,0 +1,142 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"kio/internal/config"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const sessionsFile = "sessions.json"
+
+// Session describes the regular trading hours of an exchange in its local timezone
+type Session struct {
+	Timezone    string            `json:"timezone"`
+	Open        string            `json:"open"`
+	Close       string            `json:"close"`
+	Weekdays    []time.Weekday    `json:"weekdays,omitempty"`
+	EarlyCloses map[string]string `json:"earlyCloses,omitempty"`
+	Holidays    []string          `json:"holidays,omitempty"`
+
+	location    *time.Location
+	open        int64
+	close       int64
+	earlyCloses map[string]int64
+	holidays    map[string]bool
+	weekdays    map[time.Weekday]bool
+}
+
+var sessionsCache map[string]*Session = nil
+var sessionsCacheLock = sync.Mutex{}
+
+// parseClock converts a "15:04" wall clock time into seconds after midnight
+func parseClock(s string) (int64, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return int64(t.Hour()*3600 + t.Minute()*60), nil
+}
+
+func (s *Session) init() error {
+	var err error
+	if s.location, err = time.LoadLocation(s.Timezone); err != nil {
+		return err
+	}
+	if s.open, err = parseClock(s.Open); err != nil {
+		return err
+	}
+	if s.close, err = parseClock(s.Close); err != nil {
+		return err
+	}
+	if s.close <= s.open {
+		return fmt.Errorf("session closes at %s before it opens at %s", s.Close, s.Open)
+	}
+	s.earlyCloses = make(map[string]int64)
+	for date, clock := range s.EarlyCloses {
+		if s.earlyCloses[date], err = parseClock(clock); err != nil {
+			return err
+		}
+	}
+	s.holidays = make(map[string]bool)
+	for _, date := range s.Holidays {
+		s.holidays[date] = true
+	}
+	if len(s.Weekdays) == 0 {
+		s.Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+	s.weekdays = make(map[time.Weekday]bool)
+	for _, day := range s.Weekdays {
+		s.weekdays[day] = true
+	}
+	return nil
+}
+
+// Location returns the timezone of the session
+func (s *Session) Location() *time.Location {
+	return s.location
+}
+
+// Bounds returns the unix open and close time of the session on a local calendar date, if the exchange trades that day
+func (s *Session) Bounds(year int, month time.Month, day int) (int64, int64, bool) {
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, s.location)
+	date := midnight.Format("2006-01-02")
+	if !s.weekdays[midnight.Weekday()] || s.holidays[date] {
+		return 0, 0, false
+	}
+	closeTime := s.close
+	if early, ok := s.earlyCloses[date]; ok {
+		closeTime = early
+	}
+	open := time.Date(year, month, day, int(s.open/3600), int(s.open%3600/60), 0, 0, s.location)
+	end := time.Date(year, month, day, int(closeTime/3600), int(closeTime%3600/60), 0, 0, s.location)
+	return open.Unix(), end.Unix(), true
+}
+
+// ClockTimes lists the open and every close time of the session as seconds after local midnight
+func (s *Session) ClockTimes() []int64 {
+	result := []int64{s.open, s.close}
+	for _, t := range s.earlyCloses {
+		result = append(result, t)
+	}
+	return result
+}
+
+// loadSessions reads the session definitions per exchange id from the data directory, the file is optional
+func loadSessions() (map[string]*Session, error) {
+	sessions := make(map[string]*Session)
+	file, err := os.Open(filepath.Join(config.ServiceConfig().DataDir(), sessionsFile))
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(&sessions); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", sessionsFile, err)
+	}
+	for exchange, session := range sessions {
+		if err = session.init(); err != nil {
+			return nil, fmt.Errorf("invalid session of %s: %w", exchange, err)
+		}
+	}
+	return sessions, nil
+}
+
+// ExchangeSession returns the trading session of an exchange, or nil when the exchange has no session defined
+func ExchangeSession(exchangeId string) (*Session, error) {
+	sessionsCacheLock.Lock()
+	defer sessionsCacheLock.Unlock()
+	if sessionsCache == nil {
+		sessions, err := loadSessions()
+		if err != nil {
+			return nil, err
+		}
+		sessionsCache = sessions
+	}
+	return sessionsCache[exchangeId], nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"github.com/godoji/candlestick"
+	"strings"
+)
+
+// SyntheticBroker is the broker of symbols which are derived from two other symbols
+const SyntheticBroker = "SYN"
+
+// ErrSyntheticSymbol is returned by operations on primitive candles, synthetic symbols only exist as combinations
+var ErrSyntheticSymbol = errors.New("synthetic symbols have no primitive candles")
+
+type SyntheticKind string
+
+const (
+	SyntheticRatio  = SyntheticKind("RATIO")
+	SyntheticSpread = SyntheticKind("SPREAD")
+)
+
+// Synthetic is a symbol of the form SYN:<kind>:<left>:<right> whose candles combine those of two legs
+type Synthetic struct {
+	Kind  SyntheticKind
+	Left  string
+	Right string
+}
+
+// ParseSynthetic splits a synthetic symbol into its kind and legs, legs are regular symbols of three segments
+func ParseSynthetic(symbol string) (*Synthetic, bool) {
+	xs := strings.Split(symbol, ":")
+	if len(xs) != 8 || xs[0] != SyntheticBroker {
+		return nil, false
+	}
+	kind := SyntheticKind(xs[1])
+	if kind != SyntheticRatio && kind != SyntheticSpread {
+		return nil, false
+	}
+	return &Synthetic{
+		Kind:  kind,
+		Left:  strings.Join(xs[2:5], ":"),
+		Right: strings.Join(xs[5:8], ":"),
+	}, true
+}
+
+// IsSymbolOrLeg reports whether a symbol is the given symbol or a synthetic symbol with it as one of its legs
+func IsSymbolOrLeg(symbol string, leg string) bool {
+	if symbol == leg {
+		return true
+	}
+	s, ok := ParseSynthetic(symbol)
+	return ok && (s.Left == leg || s.Right == leg)
+}
+
+// syntheticAssetInfo describes a synthetic symbol, it is listed from the later on board date of its legs
+func syntheticAssetInfo(symbol string, s *Synthetic) *candlestick.AssetInfo {
+
+	left, right := AssetInfo(s.Left), AssetInfo(s.Right)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	info := &candlestick.AssetInfo{
+		Symbol:      symbol,
+		Identifier:  candlestick.NewAssetIdentifier(SyntheticBroker, string(s.Kind), s.Left+":"+s.Right),
+		Pair:        left.Symbol + "/" + right.Symbol,
+		OnBoardDate: left.OnBoardDate,
+		Splits:      make([]candlestick.AssetSplit, 0),
+	}
+	if right.OnBoardDate > info.OnBoardDate {
+		info.OnBoardDate = right.OnBoardDate
+	}
+
+	// a ratio of two symbols with the same quote asset is priced in the base asset of the right leg
+	switch s.Kind {
+	case SyntheticRatio:
+		if left.QuoteAsset == right.QuoteAsset {
+			info.BaseAsset, info.QuoteAsset = left.BaseAsset, right.BaseAsset
+		}
+	case SyntheticSpread:
+		info.QuoteAsset = left.QuoteAsset
+	}
+
+	return info
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/godoji/candlestick"
+	"github.com/gorilla/mux"
+	"kio/internal/database"
+	"kio/internal/store"
+	"net/http"
+)
+
+type actionsResponse struct {
+	Symbol  string                   `json:"symbol"`
+	Actions []store.Action           `json:"actions"`
+	Splits  []candlestick.AssetSplit `json:"splits"`
+}
+
+// getActions lists the local corporate actions of a symbol next to the splits reported by the bridge
+func getActions(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	info := store.AssetInfo(symbol)
+	if info == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	actions, err := store.SymbolActions(symbol)
+	if err != nil {
+		sendError(w, r, http.StatusInternalServerError, codeInternalError, err.Error(), nil)
+		return
+	}
+
+	result := &actionsResponse{
+		Symbol:  symbol,
+		Actions: actions,
+		Splits:  info.Splits,
+	}
+	if result.Splits == nil {
+		result.Splits = make([]candlestick.AssetSplit, 0)
+	}
+	sendResponse(w, r, result)
+}
+
+func postAction(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	if store.AssetInfo(symbol) == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	action := store.Action{}
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid action: "+err.Error(), nil)
+		return
+	}
+
+	action, err := store.AddAction(symbol, action)
+	if errors.Is(err, store.ErrInvalidAction) {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		sendError(w, r, http.StatusInternalServerError, codeInternalError, err.Error(), nil)
+		return
+	}
+
+	// adjusted sets built before the change are stale
+	database.PurgeSymbol(symbol)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(action)
+}
+
+func deleteAction(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	id := mux.Vars(r)["id"]
+
+	ok, err := store.DeleteAction(symbol, id)
+	if err != nil {
+		sendError(w, r, http.StatusInternalServerError, codeInternalError, err.Error(), nil)
+		return
+	}
+	if !ok {
+		sendError(w, r, http.StatusNotFound, codeActionNotFound, "action not found", map[string]string{"symbol": symbol, "id": id})
+		return
+	}
+
+	database.PurgeSymbol(symbol)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/godoji/candlestick"
+	"github.com/gorilla/mux"
+	"kio/internal/config"
+	"kio/internal/database"
+	"kio/internal/historical"
+	"kio/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRedownloadBlocks limits the size of a single re-download job
+const maxRedownloadBlocks = 1000
+
+type redownloadRequest struct {
+	Symbol    string `json:"symbol"`
+	Interval  int64  `json:"interval"`
+	From      *int64 `json:"from,omitempty"`
+	To        *int64 `json:"to,omitempty"`
+	FromBlock *int64 `json:"fromBlock,omitempty"`
+	ToBlock   *int64 `json:"toBlock,omitempty"`
+}
+
+type jobsResponse struct {
+	Jobs []historical.Job `json:"jobs"`
+}
+
+// requireAdmin guards a handler with the configured admin bearer token, the admin api is disabled without a token
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := config.ServiceConfig().AdminToken()
+		if token == "" {
+			sendError(w, r, http.StatusForbidden, codeAdminDisabled, "admin api is disabled", nil)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendError(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid admin token", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func postRedownload(w http.ResponseWriter, r *http.Request) {
+
+	request := new(redownloadRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid re-download request: "+err.Error(), nil)
+		return
+	}
+
+	info := store.AssetInfo(request.Symbol)
+	if info == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": request.Symbol})
+		return
+	}
+
+	// only intervals served by the bridge are stored on disk
+	if rejectSynthetic(w, r, request.Symbol) {
+		return
+	}
+	if !isPrimitiveInterval(info, request.Interval) {
+		sendError(w, r, http.StatusBadRequest, codeInvalidInterval, "interval is not downloaded from the bridge", map[string]string{
+			"interval": strconv.FormatInt(request.Interval, 10),
+		})
+		return
+	}
+
+	// accept either a time range or a block range
+	var fromBlock, toBlock int64
+	switch {
+	case request.FromBlock != nil && request.ToBlock != nil:
+		fromBlock, toBlock = *request.FromBlock, *request.ToBlock
+	case request.From != nil && request.To != nil:
+		fromBlock = candlestick.UnixToBlock(*request.From, request.Interval)
+		toBlock = candlestick.UnixToBlock(*request.To, request.Interval)
+	default:
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "either from and to or fromBlock and toBlock are required", nil)
+		return
+	}
+	if toBlock < fromBlock {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "range end must not be before its start", nil)
+		return
+	}
+
+	// clamp the range to closed blocks that can hold data, the open block is maintained by the live updates
+	firstBlock := candlestick.UnixToBlock(info.OnBoardDate, request.Interval)
+	lastBlock := candlestick.UnixToBlock(time.Now().UTC().Unix(), request.Interval) - 1
+	if fromBlock < firstBlock {
+		fromBlock = firstBlock
+	}
+	if toBlock > lastBlock {
+		toBlock = lastBlock
+	}
+	if toBlock < fromBlock {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "range does not contain any listed blocks", nil)
+		return
+	}
+	if toBlock-fromBlock+1 > maxRedownloadBlocks {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "too many blocks in range", map[string]string{
+			"limit": strconv.Itoa(maxRedownloadBlocks),
+		})
+		return
+	}
+
+	job, err := historical.EnqueueRedownload(request.Symbol, request.Interval, fromBlock, toBlock)
+	if errors.Is(err, historical.ErrJobQueueFull) {
+		sendError(w, r, http.StatusServiceUnavailable, codeQueueFull, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		sendError(w, r, http.StatusInternalServerError, codeInternalError, err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Location", "/admin/jobs/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+func getJobs(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, r, &jobsResponse{Jobs: historical.JobList()})
+}
+
+func getJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, ok := historical.JobInfo(id)
+	if !ok {
+		sendError(w, r, http.StatusNotFound, codeJobNotFound, "job not found", map[string]string{"id": id})
+		return
+	}
+	sendResponse(w, r, &job)
+}
+
+type cachePurgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+func getCacheStats(w http.ResponseWriter, r *http.Request) {
+	stats := database.CandleCacheStats()
+	sendResponse(w, r, &stats)
+}
+
+// deleteCache purges cached candle sets of a symbol, optionally limited to an interval and a block or time range
+func deleteCache(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	symbol := q.Get("symbol")
+	if store.AssetInfo(symbol) == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	if q.Get("interval") == "" {
+		sendResponse(w, r, &cachePurgeResponse{Removed: database.PurgeSymbol(symbol)})
+		return
+	}
+	interval, err := strconv.ParseInt(q.Get("interval"), 10, 64)
+	if err != nil || interval <= 0 {
+		sendInvalidParameter(w, r, "interval")
+		return
+	}
+
+	// a range is given either in blocks of the interval or in unix time
+	from, to := "fromBlock", "toBlock"
+	if q.Get(from) == "" && q.Get(to) == "" {
+		from, to = "from", "to"
+	}
+	if q.Get(from) == "" && q.Get(to) == "" {
+		sendResponse(w, r, &cachePurgeResponse{Removed: database.PurgeInterval(symbol, interval)})
+		return
+	}
+	fromValue, err := strconv.ParseInt(q.Get(from), 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, from)
+		return
+	}
+	toValue, err := strconv.ParseInt(q.Get(to), 10, 64)
+	if err != nil || toValue < fromValue {
+		sendInvalidParameter(w, r, to)
+		return
+	}
+	if from == "from" {
+		fromValue = candlestick.UnixToBlock(fromValue, interval)
+		toValue = candlestick.UnixToBlock(toValue, interval)
+	}
+
+	sendResponse(w, r, &cachePurgeResponse{Removed: database.PurgeBlocks(symbol, interval, fromValue, toValue)})
+}
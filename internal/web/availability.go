@@ -0,0 +1,210 @@
+package web
+
+import (
+	"github.com/godoji/candlestick"
+	"github.com/gorilla/mux"
+	"kio/internal/store"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxAvailabilityBlocks limits the blocks inspected per request, the rest is reached through nextBlock
+const maxAvailabilityBlocks = 100
+
+type timeRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+type blockRange struct {
+	FromBlock int64 `json:"fromBlock"`
+	ToBlock   int64 `json:"toBlock"`
+}
+
+type blockAvailability struct {
+	Block      int64       `json:"block"`
+	Complete   bool        `json:"complete"`
+	LastUpdate int64       `json:"lastUpdate"`
+	Missing    []timeRange `json:"missing"`
+}
+
+type availabilityResponse struct {
+	Symbol      string              `json:"symbol"`
+	Interval    int64               `json:"interval"`
+	OnBoardDate int64               `json:"onBoardDate"`
+	FirstBlock  *int64              `json:"firstBlock"`
+	LastBlock   *int64              `json:"lastBlock"`
+	NextBlock   *int64              `json:"nextBlock,omitempty"`
+	Blocks      []blockAvailability `json:"blocks"`
+	Absent      []blockRange        `json:"absent"`
+}
+
+// rejectSynthetic sends an error for synthetic symbols, they are combined from their legs and never stored on disk
+func rejectSynthetic(w http.ResponseWriter, r *http.Request, symbol string) bool {
+	if _, ok := store.ParseSynthetic(symbol); !ok {
+		return false
+	}
+	sendError(w, r, http.StatusBadRequest, codeSyntheticSymbol, "synthetic symbols are not stored on disk, use their legs instead", map[string]string{"symbol": symbol})
+	return true
+}
+
+// isPrimitiveInterval checks whether an interval is served by the bridge and therefore stored on disk, synthetic
+// symbols have to be rejected beforehand
+func isPrimitiveInterval(info *candlestick.AssetInfo, interval int64) bool {
+	exchange := store.ExchangeInfo(info.Identifier.Exchange)
+	if exchange == nil {
+		return false
+	}
+	for _, resolution := range exchange.Resolution {
+		if resolution == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// missingRanges collapses consecutive missing candles into time ranges
+func missingRanges(data *candlestick.CandleSet) []timeRange {
+	result := make([]timeRange, 0)
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		if !c.Missing {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].To+data.Interval() == c.Time {
+			result[n-1].To = c.Time
+			continue
+		}
+		result = append(result, timeRange{From: c.Time, To: c.Time})
+	}
+	return result
+}
+
+// blockWindow selects at most maxAvailabilityBlocks of the blocks within [fromBlock, toBlock] and returns the next
+// block on disk after the window, if any
+func blockWindow(blocks []int64, fromBlock int64, toBlock int64) ([]int64, *int64) {
+	result := make([]int64, 0)
+	for i, block := range blocks {
+		if block < fromBlock {
+			continue
+		}
+		if block > toBlock {
+			break
+		}
+		if len(result) == maxAvailabilityBlocks {
+			return result, &blocks[i]
+		}
+		result = append(result, block)
+	}
+	return result, nil
+}
+
+// blockParameter reads an optional block number, falling back to the given default
+func blockParameter(w http.ResponseWriter, r *http.Request, name string, fallback int64) (int64, bool) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return fallback, true
+	}
+	block, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, name)
+		return 0, false
+	}
+	return block, true
+}
+
+func getAvailability(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	info := store.AssetInfo(symbol)
+	if info == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	if rejectSynthetic(w, r, symbol) {
+		return
+	}
+
+	intervalS := r.URL.Query().Get("interval")
+	interval, err := strconv.ParseInt(intervalS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "interval")
+		return
+	}
+	if !isPrimitiveInterval(info, interval) {
+		sendError(w, r, http.StatusBadRequest, codeInvalidInterval, "interval is not stored on disk", map[string]string{"interval": intervalS})
+		return
+	}
+
+	fromBlock, ok := blockParameter(w, r, "fromBlock", math.MinInt64)
+	if !ok {
+		return
+	}
+	toBlock, ok := blockParameter(w, r, "toBlock", math.MaxInt64)
+	if !ok {
+		return
+	}
+	if toBlock < fromBlock {
+		sendInvalidParameter(w, r, "toBlock")
+		return
+	}
+
+	blocks, err := store.DiskBlocks(symbol, interval)
+	if err != nil {
+		sendFetchError(w, r, err, map[string]string{"symbol": symbol, "interval": intervalS})
+		return
+	}
+	window, nextBlock := blockWindow(blocks, fromBlock, toBlock)
+
+	result := &availabilityResponse{
+		Symbol:      symbol,
+		Interval:    interval,
+		OnBoardDate: info.OnBoardDate,
+		NextBlock:   nextBlock,
+		Blocks:      make([]blockAvailability, 0, len(window)),
+		Absent:      make([]blockRange, 0),
+	}
+	if len(blocks) > 0 {
+		result.FirstBlock = &blocks[0]
+		result.LastBlock = &blocks[len(blocks)-1]
+	}
+
+	// blocks are read from disk directly so scanning them does not evict the cached sets of other requests, blocks
+	// removed since listing the directory are skipped
+	for _, block := range window {
+		data, err := store.LoadFromDisk(symbol, block, interval)
+		if err != nil {
+			sendFetchError(w, r, err, map[string]string{"symbol": symbol, "interval": intervalS})
+			return
+		}
+		if data == nil {
+			continue
+		}
+		result.Blocks = append(result.Blocks, blockAvailability{
+			Block:      block,
+			Complete:   data.IsComplete(),
+			LastUpdate: data.LastUpdate(),
+			Missing:    missingRanges(data),
+		})
+	}
+
+	// blocks between the listing and the open block which are not on disk at all
+	next := candlestick.UnixToBlock(info.OnBoardDate, interval)
+	end := candlestick.UnixToBlock(time.Now().UTC().Unix(), interval)
+	for _, block := range blocks {
+		if block > next {
+			result.Absent = append(result.Absent, blockRange{FromBlock: next, ToBlock: block - 1})
+		}
+		if block+1 > next {
+			next = block + 1
+		}
+	}
+	if next < end {
+		result.Absent = append(result.Absent, blockRange{FromBlock: next, ToBlock: end - 1})
+	}
+
+	sendResponse(w, r, result)
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"github.com/godoji/candlestick"
+	"math"
+	"testing"
+)
+
+func TestBlockWindow(t *testing.T) {
+
+	blocks := make([]int64, 0)
+	for b := int64(10); b < 10+2*maxAvailabilityBlocks; b += 2 {
+		blocks = append(blocks, b)
+	}
+
+	window, next := blockWindow(blocks, math.MinInt64, math.MaxInt64)
+	if len(window) != maxAvailabilityBlocks || window[0] != 10 || next != nil {
+		t.Fatalf("unexpected full window of %d blocks, next %v", len(window), next)
+	}
+
+	window, next = blockWindow(blocks, 11, 15)
+	if len(window) != 2 || window[0] != 12 || window[1] != 14 || next != nil {
+		t.Fatalf("unexpected window %v", window)
+	}
+
+	blocks = append(blocks, 500, 502)
+	window, next = blockWindow(blocks, 10, math.MaxInt64)
+	if len(window) != maxAvailabilityBlocks || next == nil || *next != 500 {
+		t.Fatalf("expected window to continue at block 500, got %v", next)
+	}
+	window, next = blockWindow(blocks, *next, math.MaxInt64)
+	if len(window) != 2 || next != nil {
+		t.Fatalf("unexpected continued window %v", window)
+	}
+}
+
+func TestMissingRanges(t *testing.T) {
+	data := &candlestick.CandleSet{
+		Candles: []candlestick.Candle{
+			{Time: 0, Missing: true}, {Time: 60, Missing: true}, {Time: 120}, {Time: 180, Missing: true},
+		},
+		Meta: candlestick.DataSetMeta{Interval: 60},
+	}
+	ranges := missingRanges(data)
+	if len(ranges) != 2 || ranges[0] != (timeRange{From: 0, To: 60}) || ranges[1] != (timeRange{From: 180, To: 180}) {
+		t.Fatalf("unexpected ranges %v", ranges)
+	}
+}
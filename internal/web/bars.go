@@ -0,0 +1,203 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/godoji/candlestick"
+	"github.com/gorilla/mux"
+	"kio/internal/database"
+	"kio/internal/store"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultBarLimit = 1000
+	maxBarLimit     = 10000
+)
+
+type barRequest struct {
+	symbol string
+	from   int64
+	to     int64
+	cursor *database.BarCursor
+	limit  int
+	adjust database.Adjustment
+}
+
+func encodeBarCursor(cursor *database.BarCursor) string {
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeBarCursor(s string) (*database.BarCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	cursor := new(database.BarCursor)
+	if err = json.Unmarshal(payload, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// parseBarRequest reads the window, cursor and limit shared by all bar endpoints, a cursor replaces from
+func parseBarRequest(w http.ResponseWriter, r *http.Request) (*barRequest, bool) {
+
+	q := r.URL.Query()
+	req := &barRequest{symbol: mux.Vars(r)["symbol"], limit: defaultBarLimit}
+
+	if store.AssetInfo(req.symbol) == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": req.symbol})
+		return nil, false
+	}
+
+	var err error
+	if s := q.Get("cursor"); s != "" {
+		if req.cursor, err = decodeBarCursor(s); err != nil {
+			sendInvalidParameter(w, r, "cursor")
+			return nil, false
+		}
+		req.from = req.cursor.Time
+	} else if req.from, err = strconv.ParseInt(q.Get("from"), 10, 64); err != nil {
+		sendInvalidParameter(w, r, "from")
+		return nil, false
+	}
+
+	if req.to, err = strconv.ParseInt(q.Get("to"), 10, 64); err != nil || req.to <= req.from {
+		sendInvalidParameter(w, r, "to")
+		return nil, false
+	}
+
+	if s := q.Get("limit"); s != "" {
+		if req.limit, err = strconv.Atoi(s); err != nil || req.limit <= 0 || req.limit > maxBarLimit {
+			sendInvalidParameter(w, r, "limit")
+			return nil, false
+		}
+	}
+
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
+		return nil, false
+	}
+	req.adjust = adjust
+
+	return req, true
+}
+
+// barSize reads a fixed size parameter, or derives it from the average true range when atr is given
+func barSize(w http.ResponseWriter, r *http.Request, req *barRequest) (float64, bool) {
+
+	// continued series keep the size of their cursor
+	if req.cursor != nil {
+		return req.cursor.Size, true
+	}
+
+	q := r.URL.Query()
+	if s := q.Get("atr"); s != "" {
+		period, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || period <= 0 {
+			sendInvalidParameter(w, r, "atr")
+			return 0, false
+		}
+		interval := int64(candlestick.Interval1d)
+		if s = q.Get("atrInterval"); s != "" {
+			if interval, err = strconv.ParseInt(s, 10, 64); err != nil || interval <= 0 {
+				sendInvalidParameter(w, r, "atrInterval")
+				return 0, false
+			}
+		}
+		size, err := database.AverageTrueRange(req.symbol, req.from, interval, period, req.adjust)
+		if err != nil {
+			sendFetchError(w, r, err, map[string]string{"symbol": req.symbol, "atr": q.Get("atr")})
+			return 0, false
+		}
+		return size, true
+	}
+
+	return fixedBarSize(w, r, req)
+}
+
+// fixedBarSize reads the size parameter unless continuing a series
+func fixedBarSize(w http.ResponseWriter, r *http.Request, req *barRequest) (float64, bool) {
+	if req.cursor != nil {
+		return req.cursor.Size, true
+	}
+	size, err := strconv.ParseFloat(r.URL.Query().Get("size"), 64)
+	if err != nil || size <= 0 {
+		sendInvalidParameter(w, r, "size")
+		return 0, false
+	}
+	return size, true
+}
+
+type barResponse struct {
+	*database.BarSet
+	Cursor string `json:"cursor,omitempty"`
+}
+
+func sendBars(w http.ResponseWriter, r *http.Request, req *barRequest, results *database.BarSet, err error) {
+	if err != nil {
+		sendFetchError(w, r, err, map[string]string{"symbol": req.symbol})
+		return
+	}
+	response := &barResponse{BarSet: results}
+	if results.Next != nil {
+		response.Cursor = encodeBarCursor(results.Next)
+	}
+	sendResponse(w, r, response)
+}
+
+func getRenkoBars(w http.ResponseWriter, r *http.Request) {
+	req, ok := parseBarRequest(w, r)
+	if !ok {
+		return
+	}
+	size, ok := barSize(w, r, req)
+	if !ok {
+		return
+	}
+	results, err := database.FetchRenkoBars(req.symbol, req.from, req.to, size, req.cursor, req.limit, req.adjust)
+	sendBars(w, r, req, results, err)
+}
+
+func getRangeBars(w http.ResponseWriter, r *http.Request) {
+	req, ok := parseBarRequest(w, r)
+	if !ok {
+		return
+	}
+	size, ok := barSize(w, r, req)
+	if !ok {
+		return
+	}
+	results, err := database.FetchRangeBars(req.symbol, req.from, req.to, size, req.cursor, req.limit, req.adjust)
+	sendBars(w, r, req, results, err)
+}
+
+func getVolumeBars(w http.ResponseWriter, r *http.Request) {
+	req, ok := parseBarRequest(w, r)
+	if !ok {
+		return
+	}
+
+	unit := database.BarUnit(r.URL.Query().Get("unit"))
+	if req.cursor != nil {
+		unit = req.cursor.Unit
+	} else if unit == "" {
+		unit = database.UnitVolume
+	}
+	switch unit {
+	case database.UnitVolume, database.UnitQuote, database.UnitTrades:
+	default:
+		sendInvalidParameter(w, r, "unit")
+		return
+	}
+
+	size, ok := fixedBarSize(w, r, req)
+	if !ok {
+		return
+	}
+	results, err := database.FetchVolumeBars(req.symbol, req.from, req.to, unit, size, req.cursor, req.limit, req.adjust)
+	sendBars(w, r, req, results, err)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/godoji/candlestick"
+	"kio/internal/database"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const maxBatchItems = 1000
+const batchConcurrency = 8
+
+type batchItem struct {
+	Symbol   string `json:"symbol"`
+	Segment  int64  `json:"segment"`
+	Interval int64  `json:"interval"`
+}
+
+type batchRequest struct {
+	Items []batchItem `json:"items"`
+}
+
+type batchResult struct {
+	Symbol   string                 `json:"symbol"`
+	Segment  int64                  `json:"segment"`
+	Interval int64                  `json:"interval"`
+	Data     *candlestick.CandleSet `json:"data,omitempty"`
+	Error    *errorResponse         `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+func fetchBatchItem(item batchItem, adjust database.Adjustment, useCache bool) batchResult {
+
+	result := batchResult{
+		Symbol:   item.Symbol,
+		Segment:  item.Segment,
+		Interval: item.Interval,
+	}
+
+	data, err := database.FetchCandles(item.Symbol, item.Segment, item.Interval, adjust, useCache)
+	if err != nil {
+		_, result.Error = fetchError(err, nil)
+		return result
+	}
+
+	result.Data = data
+	return result
+}
+
+func postBatch(w http.ResponseWriter, r *http.Request) {
+
+	request := new(batchRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid batch request: "+err.Error(), nil)
+		return
+	}
+
+	if len(request.Items) > maxBatchItems {
+		sendError(w, r, http.StatusBadRequest, codeInvalidRequest, "batch exceeds maximum of "+strconv.Itoa(maxBatchItems)+" items", nil)
+		return
+	}
+
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
+		return
+	}
+
+	useCache := r.URL.Query().Get("cache") != "no-cache"
+
+	// fan out requests with bounded concurrency, results keep the order of the request
+	results := make([]batchResult, len(request.Items))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, batchConcurrency)
+	for i, item := range request.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item batchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// a panicking fetch only fails its own item
+			defer func() {
+				if rec := recover(); rec != nil {
+					results[i] = batchResult{Symbol: item.Symbol, Segment: item.Segment, Interval: item.Interval}
+					_, results[i].Error = fetchError(fmt.Errorf("batch item aborted: %v", rec), nil)
+				}
+			}()
+			results[i] = fetchBatchItem(item, adjust, useCache)
+		}(i, item)
+	}
+	wg.Wait()
+
+	sendResponse(w, r, &batchResponse{Results: results})
+
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"github.com/godoji/candlestick"
+	"github.com/gorilla/mux"
+	"kio/internal/database"
+	"kio/internal/store"
+	"net/http"
+	"strconv"
+)
+
+func getCalendarCandles(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	if s := store.AssetInfo(symbol); s == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	period := database.CalendarPeriod(r.URL.Query().Get("period"))
+	switch period {
+	case database.PeriodWeek, database.PeriodMonth, database.PeriodQuarter, database.PeriodYear:
+	default:
+		sendInvalidParameter(w, r, "period")
+		return
+	}
+
+	fromS := r.URL.Query().Get("from")
+	from, err := strconv.ParseInt(fromS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "from")
+		return
+	}
+
+	toS := r.URL.Query().Get("to")
+	to, err := strconv.ParseInt(toS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "to")
+		return
+	}
+
+	if to <= from {
+		sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "to must be greater than from", map[string]string{"parameter": "to"})
+		return
+	}
+
+	// periods are built from daily candles, which are bounded like any other range request
+	if (to-from)/candlestick.Interval1d > maxRangeCandles {
+		sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "range is too large", map[string]string{
+			"parameter": "to",
+			"limit":     strconv.FormatInt(maxRangeCandles*candlestick.Interval1d, 10),
+		})
+		return
+	}
+
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
+		return
+	}
+
+	useCache := r.URL.Query().Get("cache") != "no-cache"
+
+	results, err := database.FetchCalendarCandles(symbol, period, from, to, adjust, useCache)
+	if err != nil {
+		sendFetchError(w, r, err, map[string]string{
+			"symbol": symbol,
+			"period": string(period),
+			"from":   fromS,
+			"to":     toS,
+		})
+		return
+	}
+
+	sendResponse(w, r, results)
+}
@@ -0,0 +1,276 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/dgraph-io/ristretto"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"kio/internal/database"
+	"kio/internal/metrics"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// responses smaller than this are sent uncompressed
+const compressMinSize = 1024
+
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// compressedCache holds compressed payloads of complete candle sets keyed by entity tag and encoding
+var compressedCache *ristretto.Cache
+
+func init() {
+	var err error
+	compressedCache, err = ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1 << 14,   // 16k items
+		MaxCost:     256 << 20, // 256MB
+		BufferItems: 64,
+		Metrics:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	metrics.RegisterCache("compressed_responses", compressedCache.Metrics)
+
+	// payloads are keyed by entity tag only, so any purge of candle sets invalidates all of them
+	database.OnCachePurge(func(string) {
+		compressedCache.Clear()
+	})
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+var zstdWriters = sync.Pool{
+	New: func() interface{} {
+		w, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return w
+	},
+}
+
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// negotiateEncoding picks the preferred supported encoding from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+	best := ""
+	bestQ := 0.0
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				v, err := strconv.ParseFloat(p[2:], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		if name == "*" {
+			name = encodingZstd
+		}
+		if name != encodingZstd && name != encodingGzip {
+			continue
+		}
+		// zstd wins ties as it is cheaper to decode and compresses better
+		if q > bestQ || (q == bestQ && q > 0 && name == encodingZstd) {
+			best = name
+			bestQ = q
+		}
+	}
+	return best
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int
+	wroteHeader bool
+	passThrough bool
+	discard     bool
+	buf         bytes.Buffer
+	encoder     flushWriteCloser
+	cacheKey    string
+	cacheBuf    *bytes.Buffer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+
+	// responses without body, streams and already encoded content are left alone
+	h := cw.Header()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		if code == http.StatusNotModified {
+			cw.weakenETag()
+		}
+		cw.passThrough = true
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// weakenETag marks the validator as weak since compressed representations differ byte-wise
+func (cw *compressWriter) weakenETag() {
+	etag := cw.Header().Get("ETag")
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		cw.Header().Set("ETag", "W/"+etag)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.passThrough {
+		return cw.ResponseWriter.Write(p)
+	}
+	if cw.discard {
+		return len(p), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	n, _ := cw.buf.Write(p)
+	if cw.buf.Len() >= compressMinSize {
+		if err := cw.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			log.Println(err)
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok && (cw.passThrough || cw.encoder != nil) {
+		f.Flush()
+	}
+}
+
+// startCompression writes the headers and either serves a cached payload or starts encoding the buffered body
+func (cw *compressWriter) startCompression() error {
+
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+
+	etag := h.Get("ETag")
+	cw.weakenETag()
+
+	// complete sets are compressed once per entity tag and served from memory afterwards
+	if etag != "" && cw.status == http.StatusOK && h.Get("Cache-Control") == completeCacheControl {
+		cw.cacheKey = etag + "|" + cw.encoding
+		if v, ok := compressedCache.Get(cw.cacheKey); ok {
+			cw.ResponseWriter.WriteHeader(cw.status)
+			_, err := cw.ResponseWriter.Write(v.([]byte))
+			cw.discard = true
+			return err
+		}
+		cw.cacheBuf = new(bytes.Buffer)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	var dst io.Writer = cw.ResponseWriter
+	if cw.cacheBuf != nil {
+		dst = io.MultiWriter(cw.ResponseWriter, cw.cacheBuf)
+	}
+	if cw.encoding == encodingZstd {
+		enc := zstdWriters.Get().(*zstd.Encoder)
+		enc.Reset(dst)
+		cw.encoder = enc
+	} else {
+		enc := gzipWriters.Get().(*gzip.Writer)
+		enc.Reset(dst)
+		cw.encoder = enc
+	}
+
+	_, err := cw.encoder.Write(cw.buf.Bytes())
+	cw.buf.Reset()
+	return err
+}
+
+// finish flushes small uncompressed bodies or closes the encoder and caches the payload
+func (cw *compressWriter) finish() {
+
+	if cw.passThrough || cw.discard {
+		return
+	}
+
+	if cw.encoder == nil {
+		if !cw.wroteHeader {
+			return
+		}
+		cw.ResponseWriter.WriteHeader(cw.status)
+		_, _ = cw.ResponseWriter.Write(cw.buf.Bytes())
+		return
+	}
+
+	if err := cw.encoder.Close(); err != nil {
+		log.Println(err)
+		return
+	}
+	switch enc := cw.encoder.(type) {
+	case *zstd.Encoder:
+		enc.Reset(nil)
+		zstdWriters.Put(enc)
+	case *gzip.Writer:
+		enc.Reset(nil)
+		gzipWriters.Put(enc)
+	}
+
+	if cw.cacheBuf != nil {
+		payload := cw.cacheBuf.Bytes()
+		compressedCache.Set(cw.cacheKey, payload, int64(len(payload)))
+	}
+}
+
+// compressionMiddleware negotiates gzip or zstd compression of response bodies
+type compressionMiddleware struct{}
+
+func (m *compressionMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+
+	// web socket upgrades need direct access to the connection
+	if r.Header.Get("Upgrade") != "" {
+		next(w, r)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" || r.Method == http.MethodHead {
+		next(w, r)
+		return
+	}
+
+	cw := &compressWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+	}
+	next(cw, r)
+	cw.finish()
+}
@@ -0,0 +1,25 @@
+package web
+
+import (
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+
+	cases := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    encodingGzip,
+		"gzip, deflate, br, zstd": encodingZstd,
+		"zstd;q=0.5, gzip":        encodingGzip,
+		"zstd;q=0, gzip;q=0.1":    encodingGzip,
+		"*":                       encodingZstd,
+	}
+
+	for header, expected := range cases {
+		if v := negotiateEncoding(header); v != expected {
+			t.Errorf("expected %q for %q but got %q", expected, header, v)
+		}
+	}
+
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"kio/internal/database"
+	"kio/internal/store"
+	"log"
+	"net/http"
+)
+
+const (
+	codeSymbolNotFound      = "SYMBOL_NOT_FOUND"
+	codeExchangeNotFound    = "EXCHANGE_NOT_FOUND"
+	codeBlockNotDownloaded  = "BLOCK_NOT_DOWNLOADED"
+	codeInvalidInterval     = "INVALID_INTERVAL"
+	codeBeforeOnBoardDate   = "BEFORE_ONBOARD_DATE"
+	codeInvalidParameter    = "INVALID_PARAMETER"
+	codeInvalidRequest      = "INVALID_REQUEST"
+	codeUpstreamUnavailable = "UPSTREAM_UNAVAILABLE"
+	codeInternalError       = "INTERNAL_ERROR"
+	codeUnauthorized        = "UNAUTHORIZED"
+	codeAdminDisabled       = "ADMIN_DISABLED"
+	codeJobNotFound         = "JOB_NOT_FOUND"
+	codeQueueFull           = "QUEUE_FULL"
+	codeNoSession           = "NO_SESSION"
+	codeActionNotFound      = "ACTION_NOT_FOUND"
+	codeNoConversion        = "NO_CONVERSION"
+	codeSyntheticSymbol     = "SYNTHETIC_SYMBOL"
+	codeNoConversionRates   = "NO_CONVERSION_RATES"
+)
+
+type errorResponse struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// sendError writes an error body in the negotiated format, falling back to json for non-binary formats
+func sendError(w http.ResponseWriter, r *http.Request, status int, code string, message string, details map[string]string) {
+
+	body := &errorResponse{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+
+	format, _ := negotiateFormat(r)
+	if format == formatBinary {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(status)
+		_ = gob.NewEncoder(w).Encode(body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// sendInvalidParameter reports a missing or malformed query parameter
+func sendInvalidParameter(w http.ResponseWriter, r *http.Request, parameter string) {
+	sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "invalid "+parameter+" parameter", map[string]string{
+		"parameter": parameter,
+		"value":     r.URL.Query().Get(parameter),
+	})
+}
+
+// fetchError maps errors of the database and store packages onto a status and an error body
+func fetchError(err error, details map[string]string) (int, *errorResponse) {
+	e := &errorResponse{
+		Message: err.Error(),
+		Details: details,
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, database.ErrSymbolNotFound):
+		status, e.Code = http.StatusNotFound, codeSymbolNotFound
+	case errors.Is(err, database.ErrExchangeNotFound):
+		status, e.Code = http.StatusNotFound, codeExchangeNotFound
+	case errors.Is(err, database.ErrBlockNotDownloaded):
+		status, e.Code = http.StatusNotFound, codeBlockNotDownloaded
+	case errors.Is(err, database.ErrBeforeOnBoardDate):
+		status, e.Code = http.StatusNotFound, codeBeforeOnBoardDate
+	case errors.Is(err, database.ErrInvalidInterval):
+		status, e.Code = http.StatusBadRequest, codeInvalidInterval
+	case errors.Is(err, database.ErrInvalidBarSize), errors.Is(err, database.ErrInvalidBarUnit):
+		status, e.Code = http.StatusBadRequest, codeInvalidParameter
+	case errors.Is(err, database.ErrUnknownIndicator), errors.Is(err, database.ErrInvalidIndicatorArg):
+		status, e.Code = http.StatusBadRequest, codeInvalidParameter
+	case errors.Is(err, database.ErrNoSession):
+		status, e.Code = http.StatusBadRequest, codeNoSession
+	case errors.Is(err, store.ErrSyntheticSymbol):
+		status, e.Code = http.StatusBadRequest, codeSyntheticSymbol
+	case errors.Is(err, database.ErrNoConversionRates):
+		status, e.Code = http.StatusNotFound, codeNoConversionRates
+	case errors.Is(err, store.ErrNoConversion):
+		status, e.Code = http.StatusBadRequest, codeNoConversion
+	case errors.Is(err, store.ErrCandleServiceUnavailable):
+		status, e.Code = http.StatusBadGateway, codeUpstreamUnavailable
+	default:
+		log.Println(err)
+		e.Code = codeInternalError
+	}
+	return status, e
+}
+
+func sendFetchError(w http.ResponseWriter, r *http.Request, err error, details map[string]string) {
+	status, e := fetchError(err, details)
+	sendError(w, r, status, e.Code, e.Message, e.Details)
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/godoji/candlestick"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// complete sets are immutable per entity tag, a purge or re-download that edits them produces a new tag
+const (
+	completeCacheControl   = "public, max-age=31536000, immutable"
+	incompleteCacheControl = "public, max-age=10"
+)
+
+// candleSetETag derives a strong validator for a candle set in the representation requested by r, the content is
+// always hashed since even complete sets change when blocks are re-downloaded or corporate actions are edited
+func candleSetETag(r *http.Request, data *candlestick.CandleSet) string {
+
+	h := fnv.New64a()
+
+	// the representation depends on the negotiated format and every query option
+	format, _ := negotiateCandleFormat(r)
+	_, _ = h.Write([]byte(format + "|" + r.URL.Query().Encode() + "|" + data.UID() + "|"))
+
+	buf := make([]byte, 8)
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		for _, v := range []float64{c.Open, c.High, c.Low, c.Close, c.Volume, c.TakerVolume} {
+			binary.BigEndian.PutUint64(buf, math.Float64bits(v))
+			_, _ = h.Write(buf)
+		}
+		binary.BigEndian.PutUint64(buf, uint64(c.NumberOfTrades))
+		_, _ = h.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(c.Time))
+		_, _ = h.Write(buf)
+		if c.Missing {
+			_, _ = h.Write([]byte{1})
+		} else {
+			_, _ = h.Write([]byte{0})
+		}
+	}
+
+	return "\"" + hex.EncodeToString(h.Sum(nil)) + "\""
+}
+
+// etagMatches checks an If-None-Match header against an entity tag using weak comparison
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotModified sets the caching headers of a candle set and answers with 304 when the client holds it already
+func checkNotModified(w http.ResponseWriter, r *http.Request, data *candlestick.CandleSet) bool {
+
+	etag := candleSetETag(r, data)
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept")
+	if data.IsComplete() {
+		w.Header().Set("Cache-Control", completeCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", incompleteCacheControl)
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompleteSetETagFollowsContent(t *testing.T) {
+
+	r := httptest.NewRequest("GET", "/market/TEST:SPOT:ETAG?segment=6000&interval=60", nil)
+	data := realTimeSet("TEST:SPOT:ETAG", 6000, 1, 2)
+	data.Meta.Complete = true
+
+	etag := candleSetETag(r, data)
+	if etag != candleSetETag(r, realTimeSet("TEST:SPOT:ETAG", 6000, 1, 2)) {
+		t.Errorf("equal content produced different tags")
+	}
+
+	// a re-downloaded block with corrected candles must not validate against the old tag
+	data.Candles[1].Close = 3
+	if etag == candleSetETag(r, data) {
+		t.Errorf("changed content kept its tag")
+	}
+}
+
+func TestCompleteSetIsImmutable(t *testing.T) {
+
+	r := httptest.NewRequest("GET", "/market/TEST:SPOT:ETAG?segment=6000&interval=60", nil)
+	data := realTimeSet("TEST:SPOT:ETAG", 6000, 1)
+	data.Meta.Complete = true
+
+	w := httptest.NewRecorder()
+	checkNotModified(w, r, data)
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("complete set sent with %q", cc)
+	}
+
+	data.Meta.Complete = false
+	w = httptest.NewRecorder()
+	checkNotModified(w, r, data)
+	if cc := w.Header().Get("Cache-Control"); strings.Contains(cc, "immutable") {
+		t.Errorf("incomplete set sent with %q", cc)
+	}
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"kio/internal/events"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const eventHeartbeatPeriod = 15 * time.Second
+
+// eventStreamsDone is closed when the server shuts down to release open event streams
+var eventStreamsDone = make(chan interface{})
+
+func closeEventStreams() {
+	close(eventStreamsDone)
+}
+
+func writeEvent(w http.ResponseWriter, e *events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+	return err
+}
+
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendError(w, r, http.StatusInternalServerError, codeInternalError, "streaming is not supported", nil)
+		return
+	}
+
+	// optionally only forward events of a single symbol
+	symbol := r.URL.Query().Get("symbol")
+
+	// reconnecting clients resume after the last event they have seen, new clients only receive new events
+	lastEventS := r.Header.Get("Last-Event-ID")
+	if lastEventS == "" {
+		lastEventS = r.URL.Query().Get("lastEventId")
+	}
+	var backlog []events.Event
+	var ch chan events.Event
+	if lastEventS != "" {
+		lastEvent, err := strconv.ParseUint(lastEventS, 10, 64)
+		if err != nil {
+			sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "invalid last event id", map[string]string{"lastEventId": lastEventS})
+			return
+		}
+		backlog, ch = events.Subscribe(lastEvent)
+	} else {
+		ch = events.SubscribeNew()
+	}
+	defer events.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for i := range backlog {
+		if symbol != "" && backlog[i].Symbol != symbol && backlog[i].Type != events.AuditCompleted {
+			continue
+		}
+		if err := writeEvent(w, &backlog[i]); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatPeriod)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				// subscriber fell behind, the client reconnects using the last event id
+				return
+			}
+			if symbol != "" && e.Symbol != symbol && e.Type != events.AuditCompleted {
+				continue
+			}
+			if err := writeEvent(w, &e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-eventStreamsDone:
+			return
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/godoji/candlestick"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	timeStyleUnix    = "unix"
+	timeStyleRFC3339 = "rfc3339"
+)
+
+type textOptions struct {
+	timeStyle   string
+	omitMissing bool
+}
+
+type ndjsonCandle struct {
+	Time           interface{} `json:"t"`
+	Open           float64     `json:"o"`
+	High           float64     `json:"h"`
+	Low            float64     `json:"l"`
+	Close          float64     `json:"c"`
+	Volume         float64     `json:"v"`
+	TakerVolume    float64     `json:"tv"`
+	NumberOfTrades int64       `json:"not"`
+	Missing        bool        `json:"m"`
+}
+
+var csvHeader = []string{"time", "open", "high", "low", "close", "volume", "taker_volume", "number_of_trades", "missing"}
+
+// parseTextOptions reads the time and missing parameters used by the text based formats
+func parseTextOptions(r *http.Request) (*textOptions, error) {
+	opts := &textOptions{
+		timeStyle: timeStyleUnix,
+	}
+	switch v := r.URL.Query().Get("time"); v {
+	case "", timeStyleUnix:
+	case timeStyleRFC3339:
+		opts.timeStyle = timeStyleRFC3339
+	default:
+		return nil, errors.New("invalid time parameter: " + v)
+	}
+	switch v := r.URL.Query().Get("missing"); v {
+	case "", "include":
+	case "omit":
+		opts.omitMissing = true
+	default:
+		return nil, errors.New("invalid missing parameter: " + v)
+	}
+	return opts, nil
+}
+
+func formatTimestamp(t int64, style string) string {
+	if style == timeStyleRFC3339 {
+		return time.Unix(t, 0).UTC().Format(time.RFC3339)
+	}
+	return strconv.FormatInt(t, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func encodeCandlesCSV(w io.Writer, data *candlestick.CandleSet, opts *textOptions) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	record := make([]string, len(csvHeader))
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		if opts.omitMissing && c.Missing {
+			continue
+		}
+		record[0] = formatTimestamp(c.Time, opts.timeStyle)
+		record[1] = formatFloat(c.Open)
+		record[2] = formatFloat(c.High)
+		record[3] = formatFloat(c.Low)
+		record[4] = formatFloat(c.Close)
+		record[5] = formatFloat(c.Volume)
+		record[6] = formatFloat(c.TakerVolume)
+		record[7] = strconv.FormatInt(c.NumberOfTrades, 10)
+		record[8] = strconv.FormatBool(c.Missing)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func encodeCandlesNDJSON(w io.Writer, data *candlestick.CandleSet, opts *textOptions) error {
+	encoder := json.NewEncoder(w)
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		if opts.omitMissing && c.Missing {
+			continue
+		}
+		line := ndjsonCandle{
+			Time:           c.Time,
+			Open:           c.Open,
+			High:           c.High,
+			Low:            c.Low,
+			Close:          c.Close,
+			Volume:         c.Volume,
+			TakerVolume:    c.TakerVolume,
+			NumberOfTrades: c.NumberOfTrades,
+			Missing:        c.Missing,
+		}
+		if opts.timeStyle == timeStyleRFC3339 {
+			line.Time = formatTimestamp(c.Time, opts.timeStyle)
+		}
+		if err := encoder.Encode(&line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var arrowFields = []arrow.Field{
+	{Name: "time", Type: arrow.FixedWidthTypes.Timestamp_s},
+	{Name: "open", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "high", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "low", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "close", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "volume", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "taker_volume", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "number_of_trades", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "missing", Type: arrow.FixedWidthTypes.Boolean},
+}
+
+// arrowSchema creates a schema carrying the data set meta as schema metadata
+func arrowSchema(meta *candlestick.DataSetMeta) *arrow.Schema {
+	md := arrow.NewMetadata(
+		[]string{"uid", "block", "complete", "lastUpdate", "symbol", "interval"},
+		[]string{
+			meta.UID,
+			strconv.FormatInt(meta.Block, 10),
+			strconv.FormatBool(meta.Complete),
+			strconv.FormatInt(meta.LastUpdate, 10),
+			meta.Symbol,
+			strconv.FormatInt(meta.Interval, 10),
+		},
+	)
+	return arrow.NewSchema(arrowFields, &md)
+}
+
+func encodeCandlesArrow(w io.Writer, data *candlestick.CandleSet) error {
+
+	schema := arrowSchema(&data.Meta)
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	n := len(data.Candles)
+	times := builder.Field(0).(*array.TimestampBuilder)
+	opens := builder.Field(1).(*array.Float64Builder)
+	highs := builder.Field(2).(*array.Float64Builder)
+	lows := builder.Field(3).(*array.Float64Builder)
+	closes := builder.Field(4).(*array.Float64Builder)
+	volumes := builder.Field(5).(*array.Float64Builder)
+	takerVolumes := builder.Field(6).(*array.Float64Builder)
+	trades := builder.Field(7).(*array.Int64Builder)
+	missing := builder.Field(8).(*array.BooleanBuilder)
+	for i := 0; i < builder.Schema().NumFields(); i++ {
+		builder.Field(i).Reserve(n)
+	}
+
+	for i := range data.Candles {
+		c := &data.Candles[i]
+		times.UnsafeAppend(arrow.Timestamp(c.Time))
+		opens.UnsafeAppend(c.Open)
+		highs.UnsafeAppend(c.High)
+		lows.UnsafeAppend(c.Low)
+		closes.UnsafeAppend(c.Close)
+		volumes.UnsafeAppend(c.Volume)
+		takerVolumes.UnsafeAppend(c.TakerVolume)
+		trades.UnsafeAppend(c.NumberOfTrades)
+		missing.UnsafeAppend(c.Missing)
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema))
+	if err := writer.Write(record); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"bytes"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/godoji/candlestick"
+	"strings"
+	"testing"
+)
+
+func TestCandlesCSV(t *testing.T) {
+
+	data := &candlestick.CandleSet{
+		Candles: []candlestick.Candle{
+			{Open: 1.5, High: 2, Low: 1, Close: 1.75, Volume: 10, TakerVolume: 4, NumberOfTrades: 3, Time: 0},
+			{Time: 60, Missing: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := encodeCandlesCSV(&buf, data, &textOptions{timeStyle: timeStyleRFC3339, omitMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one candle, got %d lines", len(lines))
+	}
+	if lines[1] != "1970-01-01T00:00:00Z,1.5,2,1,1.75,10,4,3,false" {
+		t.Fail()
+	}
+
+}
+
+func TestCandlesArrow(t *testing.T) {
+
+	data := &candlestick.CandleSet{
+		Candles: []candlestick.Candle{
+			{Open: 1.5, High: 2, Low: 1, Close: 1.75, Volume: 10, TakerVolume: 4, NumberOfTrades: 3, Time: 0},
+			{Time: 60, Missing: true},
+		},
+		Meta: candlestick.DataSetMeta{UID: "A:B:C:60:0", Symbol: "A:B:C", Interval: 60},
+	}
+
+	var buf bytes.Buffer
+	if err := encodeCandlesArrow(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Release()
+
+	if uid, ok := reader.Schema().Metadata().GetValue("uid"); !ok || uid != data.Meta.UID {
+		t.Fail()
+	}
+	if !reader.Next() {
+		t.Fatal("expected a record batch")
+	}
+	record := reader.Record()
+	if record.NumRows() != 2 {
+		t.Fail()
+	}
+	if record.Column(4).(*array.Float64).Value(0) != 1.75 {
+		t.Fail()
+	}
+	if !record.Column(8).(*array.Boolean).Value(1) {
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kio/internal/config"
+	"kio/internal/historical"
+	"kio/internal/store"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type healthCheck struct {
+	OK          bool   `json:"ok"`
+	Message     string `json:"message,omitempty"`
+	LastSuccess int64  `json:"lastSuccess"`
+}
+
+type readinessResponse struct {
+	Ready  bool                    `json:"ready"`
+	Checks map[string]*healthCheck `json:"checks"`
+}
+
+var errHistoryBusy = errors.New("historical audit is in progress")
+
+var lastCheckSuccess = make(map[string]int64)
+var lastCheckSuccessLock = sync.Mutex{}
+
+// runCheck executes a readiness check and keeps track of the last time it passed
+func runCheck(name string, check func() error) *healthCheck {
+	err := check()
+	lastCheckSuccessLock.Lock()
+	defer lastCheckSuccessLock.Unlock()
+	if err == nil {
+		lastCheckSuccess[name] = time.Now().UTC().Unix()
+	}
+	result := &healthCheck{
+		OK:          err == nil,
+		LastSuccess: lastCheckSuccess[name],
+	}
+	if err != nil {
+		result.Message = err.Error()
+	}
+	return result
+}
+
+func checkAudit() error {
+	if historical.IsHistoryBusy() {
+		return errHistoryBusy
+	}
+	return nil
+}
+
+// bridgeCheckTimeout bounds the bridge probe so readiness requests can not hang on an unresponsive bridge, while
+// bridgeCheckInterval limits how often the bridge, which serves the full market info on every probe, is asked
+const (
+	bridgeCheckTimeout  = 3 * time.Second
+	bridgeCheckInterval = 30 * time.Second
+)
+
+// cachedProbe runs a probe at most once per interval and answers with its last result in between, concurrent
+// checks wait for the running probe instead of starting their own
+type cachedProbe struct {
+	interval time.Duration
+	probe    func(ctx context.Context) error
+	checked  time.Time
+	err      error
+	lock     sync.Mutex
+}
+
+func (p *cachedProbe) check() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.checked.IsZero() && time.Since(p.checked) < p.interval {
+		return p.err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bridgeCheckTimeout)
+	defer cancel()
+	p.err = p.probe(ctx)
+	p.checked = time.Now()
+	return p.err
+}
+
+var bridgeProbe = &cachedProbe{interval: bridgeCheckInterval, probe: store.PingBridge}
+
+// checkMarketInfo probes the bridge directly, the market info itself is memoized after the first success
+func checkMarketInfo() error {
+	return bridgeProbe.check()
+}
+
+func checkDataDir() error {
+	file, err := os.CreateTemp(config.ServiceConfig().DataDir(), ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+func sendHealth(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func getHealth(w http.ResponseWriter, _ *http.Request) {
+	sendHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func getReadiness(w http.ResponseWriter, _ *http.Request) {
+
+	result := &readinessResponse{
+		Ready: true,
+		Checks: map[string]*healthCheck{
+			"audit":      runCheck("audit", checkAudit),
+			"marketInfo": runCheck("marketInfo", checkMarketInfo),
+			"dataDir":    runCheck("dataDir", checkDataDir),
+		},
+	}
+	for _, check := range result.Checks {
+		result.Ready = result.Ready && check.OK
+	}
+
+	status := http.StatusOK
+	if !result.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	sendHealth(w, status, result)
+}
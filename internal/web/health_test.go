@@ -0,0 +1,34 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBridgeProbeIsCached(t *testing.T) {
+
+	calls := 0
+	errDown := errors.New("bridge is down")
+	p := &cachedProbe{interval: time.Hour, probe: func(ctx context.Context) error {
+		calls++
+		return errDown
+	}}
+
+	for i := 0; i < 3; i++ {
+		if err := p.check(); !errors.Is(err, errDown) {
+			t.Fatalf("probe result was lost: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("bridge probed %d times within the interval", calls)
+	}
+
+	// an expired result probes again
+	p.checked = time.Now().Add(-2 * time.Hour)
+	_ = p.check()
+	if calls != 2 {
+		t.Errorf("expired result was not refreshed")
+	}
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"github.com/gorilla/mux"
+	"kio/internal/database"
+	"kio/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func getIndicator(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	if s := store.AssetInfo(symbol); s == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	segmentS := r.URL.Query().Get("segment")
+	segment, err := strconv.ParseInt(segmentS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "segment")
+		return
+	}
+
+	intervalS := r.URL.Query().Get("interval")
+	interval, err := strconv.ParseInt(intervalS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "interval")
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+
+	// parameters are a comma separated list of integers, the defaults of the indicator apply when left out
+	params := make([]int, 0)
+	if paramsS := r.URL.Query().Get("params"); paramsS != "" {
+		for _, s := range strings.Split(paramsS, ",") {
+			p, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				sendInvalidParameter(w, r, "params")
+				return
+			}
+			params = append(params, p)
+		}
+	}
+
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
+		return
+	}
+
+	useCache := r.URL.Query().Get("cache") != "no-cache"
+
+	results, err := database.FetchIndicator(symbol, segment, interval, name, params, adjust, useCache)
+	if err != nil {
+		sendFetchError(w, r, err, map[string]string{
+			"symbol":   symbol,
+			"segment":  segmentS,
+			"interval": intervalS,
+			"name":     name,
+			"params":   r.URL.Query().Get("params"),
+		})
+		return
+	}
+
+	sendResponse(w, r, results)
+}
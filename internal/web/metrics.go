@@ -0,0 +1,38 @@
+package web
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/urfave/negroni"
+	"kio/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// metricsMiddleware records request counts and latencies labelled by route template
+type metricsMiddleware struct {
+	router *mux.Router
+}
+
+func (m *metricsMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+
+	timerStart := time.Now()
+
+	// resolve the route template to keep label cardinality bounded
+	route := "unmatched"
+	var match mux.RouteMatch
+	if m.router.Match(r, &match) && match.Route != nil {
+		if template, err := match.Route.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+
+	next(w, r)
+
+	status := http.StatusOK
+	if rw, ok := w.(negroni.ResponseWriter); ok && rw.Status() != 0 {
+		status = rw.Status()
+	}
+	metrics.HttpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+	metrics.HttpDuration.WithLabelValues(route, r.Method).Observe(time.Since(timerStart).Seconds())
+}
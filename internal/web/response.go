@@ -1,6 +1,7 @@
 package web
 
 import (
+	"bytes"
 	"encoding/gob"
 	"encoding/json"
 	"github.com/godoji/candlestick"
@@ -9,6 +10,78 @@ import (
 	"strings"
 )
 
+const (
+	formatJSON   = "json"
+	formatBinary = "binary"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+	formatArrow  = "arrow"
+)
+
+// negotiateFormat resolves the response format from the format parameter or the accept header
+func negotiateFormat(r *http.Request) (string, bool) {
+
+	// explicit format parameter takes precedence over the accept header
+	switch r.URL.Query().Get("format") {
+	case "":
+	case formatJSON:
+		return formatJSON, true
+	case formatBinary:
+		return formatBinary, true
+	case formatCSV:
+		return formatCSV, true
+	case formatNDJSON:
+		return formatNDJSON, true
+	case formatArrow:
+		return formatArrow, true
+	default:
+		return "", false
+	}
+
+	// try to satisfy accept header
+	accepts := r.Header.Get("Accept")
+
+	// send as json when nothing is specified
+	if accepts == "" {
+		return formatJSON, true
+	}
+	if strings.Index(accepts, "application/json") != -1 {
+		return formatJSON, true
+	}
+	if strings.Index(accepts, "application/octet-stream") != -1 {
+		return formatBinary, true
+	}
+	if strings.Index(accepts, "text/csv") != -1 {
+		return formatCSV, true
+	}
+	if strings.Index(accepts, "application/x-ndjson") != -1 {
+		return formatNDJSON, true
+	}
+	if strings.Index(accepts, "application/vnd.apache.arrow.stream") != -1 {
+		return formatArrow, true
+	}
+
+	// send as json when any is requested
+	if strings.Index(accepts, "*/*") != -1 {
+		return formatJSON, true
+	}
+
+	return "", false
+}
+
+// negotiateCandleFormat resolves the response format for candle sets
+func negotiateCandleFormat(r *http.Request) (string, bool) {
+
+	format, ok := negotiateFormat(r)
+
+	// binary streams take precedence over other accepted types for candle sets
+	if ok && r.URL.Query().Get("format") == "" && strings.Index(r.Header.Get("Accept"), "application/octet-stream") != -1 {
+		format = formatBinary
+	}
+
+	return format, ok
+}
+
 func sendAsJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -23,56 +96,69 @@ func sendAsBinary(w http.ResponseWriter, data interface{}) {
 
 func sendResponseCandles(w http.ResponseWriter, r *http.Request, data *candlestick.CandleSet) {
 
-	// try to satisfy accept header
-	accepts := r.Header.Get("Accept")
+	format, ok := negotiateCandleFormat(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
 
-	// write in custom binary format if it is a binary stream request
-	if strings.Index(accepts, "application/octet-stream") != -1 {
+	switch format {
+	case formatBinary:
+		// write in custom binary format if it is a binary stream request
 		payload, err := candlestick.EncodeCandleSet(data)
 		if err != nil {
 			log.Println(err)
-			http.Error(w, "could not encode dataset: "+err.Error(), http.StatusInternalServerError)
+			sendError(w, r, http.StatusInternalServerError, codeInternalError, "could not encode dataset: "+err.Error(), nil)
 			return
 		}
 		w.Header().Set("Content-Type", "application/octet-stream")
 		_, _ = w.Write(payload)
-		return
+	case formatArrow:
+		// write columnar arrow ipc stream
+		var buf bytes.Buffer
+		if err := encodeCandlesArrow(&buf, data); err != nil {
+			log.Println(err)
+			sendError(w, r, http.StatusInternalServerError, codeInternalError, "could not encode dataset: "+err.Error(), nil)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+		_, _ = w.Write(buf.Bytes())
+	case formatCSV, formatNDJSON:
+		opts, err := parseTextOptions(r)
+		if err != nil {
+			sendError(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error(), nil)
+			return
+		}
+		if format == formatCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+			err = encodeCandlesCSV(w, data, opts)
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			err = encodeCandlesNDJSON(w, data, opts)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	default:
+		sendResponse(w, r, data)
 	}
 
-	sendResponse(w, r, data)
-
 }
 
 func sendResponse(w http.ResponseWriter, r *http.Request, data interface{}) {
 
-	// try to satisfy accept header
-	accepts := r.Header.Get("Accept")
-
-	// send as json when nothing is specified
-	if accepts == "" {
-		sendAsJSON(w, data)
-		return
-	}
+	format, _ := negotiateFormat(r)
 
-	// send as json when json is requested
-	if strings.Index(accepts, "application/json") != -1 {
+	switch format {
+	case formatJSON:
 		sendAsJSON(w, data)
-		return
-	}
-
-	// send as gob when binary is requested
-	if strings.Index(accepts, "application/octet-stream") != -1 {
+	case formatBinary:
 		sendAsBinary(w, data)
-		return
-	}
-
-	// send as json when any is requested
-	if strings.Index(accepts, "*/*") != -1 {
-		sendAsJSON(w, data)
-		return
+	default:
+		// deny other types
+		w.WriteHeader(http.StatusNotAcceptable)
 	}
 
-	// deny other types
-	w.WriteHeader(http.StatusNotAcceptable)
-
 }
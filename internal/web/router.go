@@ -3,23 +3,37 @@ package web
 import (
 	"github.com/godoji/candlestick"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"kio/internal/database"
 	"kio/internal/store"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func getMarketInfo(w http.ResponseWriter, r *http.Request) {
 	info, err := store.MarketInfo()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sendError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, err.Error(), nil)
 		return
 	}
 	sendResponse(w, r, info)
 }
 
+type nativeInterval struct {
+	Interval    int64 `json:"interval"`
+	SubInterval int64 `json:"subInterval,omitempty"`
+}
+
+type customIntervals struct {
+	MultipleOf   []int64 `json:"multipleOf"`
+	MaxSubBlocks int64   `json:"maxSubBlocks"`
+}
+
 type intervalsResponse struct {
-	Intervals []int64 `json:"intervals"`
+	Intervals []int64          `json:"intervals"`
+	Native    []nativeInterval `json:"native"`
+	Custom    customIntervals  `json:"custom"`
 }
 
 type symbolsResponse struct {
@@ -29,14 +43,39 @@ type symbolsResponse struct {
 func getIntervalList(w http.ResponseWriter, r *http.Request) {
 	result := &intervalsResponse{
 		Intervals: candlestick.IntervalList,
+		Native:    make([]nativeInterval, 0, len(candlestick.IntervalList)),
+		Custom: customIntervals{
+			MultipleOf:   make([]int64, 0),
+			MaxSubBlocks: database.MaxCustomSubBlocks,
+		},
+	}
+	for _, interval := range candlestick.IntervalList {
+		result.Native = append(result.Native, nativeInterval{
+			Interval:    interval,
+			SubInterval: candlestick.IntervalMap[interval],
+		})
 	}
+
+	// custom intervals can be built from any primitive resolution, the list stays empty while the bridge is down
+	if info, err := store.MarketInfo(); err == nil {
+		seen := make(map[int64]bool)
+		for _, exchange := range info.Exchanges {
+			for _, resolution := range exchange.Resolution {
+				if !seen[resolution] {
+					seen[resolution] = true
+					result.Custom.MultipleOf = append(result.Custom.MultipleOf, resolution)
+				}
+			}
+		}
+	}
+
 	sendResponse(w, r, result)
 }
 
 func getSymbols(w http.ResponseWriter, r *http.Request) {
 	symbols, err := store.SymbolList()
 	if err != nil {
-		http.Error(w, "could not find exchange info", http.StatusInternalServerError)
+		sendError(w, r, http.StatusBadGateway, codeUpstreamUnavailable, "could not find exchange info", nil)
 		return
 	}
 	result := &symbolsResponse{
@@ -49,34 +88,169 @@ func getCandles(w http.ResponseWriter, r *http.Request) {
 
 	symbol := mux.Vars(r)["symbol"]
 	if s := store.AssetInfo(symbol); s == nil {
-		http.Error(w, "symbol not found", http.StatusNotFound)
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
 		return
 	}
 
 	segmentS := r.URL.Query().Get("segment")
 	segment, err := strconv.ParseInt(segmentS, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid segment parameter", http.StatusBadRequest)
+		sendInvalidParameter(w, r, "segment")
 		return
 	}
 
 	intervalS := r.URL.Query().Get("interval")
 	interval, err := strconv.ParseInt(intervalS, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid interval parameter", http.StatusBadRequest)
+		sendInvalidParameter(w, r, "interval")
+		return
+	}
+
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
 		return
 	}
 
 	useCache := r.URL.Query().Get("cache") != "no-cache"
 
-	results, err := database.FetchCandles(symbol, segment, interval, useCache)
+	kind := database.CandleType(r.URL.Query().Get("type"))
+	if kind == "" {
+		kind = database.TypeCandles
+	}
+	if kind != database.TypeCandles && kind != database.TypeHeikinAshi {
+		sendInvalidParameter(w, r, "type")
+		return
+	}
 
+	// prices can be converted into another quote asset, only plain candles on utc blocks are converted
+	quote := r.URL.Query().Get("quote")
+	if quote != "" && (kind != database.TypeCandles || r.URL.Query().Get("align") == "session") {
+		sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "only utc aligned candles can be converted", map[string]string{"parameter": "quote"})
+		return
+	}
+
+	// candles are aligned on utc block boundaries unless anchored to the trading session of the exchange
+	var results *candlestick.CandleSet
+	switch r.URL.Query().Get("align") {
+	case "", "utc":
+		if quote != "" {
+			var path []store.ConversionStep
+			results, path, err = database.FetchConvertedCandles(symbol, segment, interval, quote, adjust, useCache)
+			if err == nil && len(path) > 0 {
+				w.Header().Set("Conversion-Path", conversionPathHeader(path))
+			}
+		} else if kind == database.TypeHeikinAshi {
+			results, err = database.FetchHeikinAshi(symbol, segment, interval, adjust, useCache)
+		} else {
+			results, err = database.FetchCandles(symbol, segment, interval, adjust, useCache)
+		}
+	case "session":
+		if kind != database.TypeCandles {
+			sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "session aligned candles can not be transformed", map[string]string{"parameter": "type"})
+			return
+		}
+		results, err = database.FetchSessionCandles(symbol, segment, interval, adjust, useCache)
+	default:
+		sendInvalidParameter(w, r, "align")
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sendFetchError(w, r, err, map[string]string{
+			"symbol":   symbol,
+			"segment":  segmentS,
+			"interval": intervalS,
+		})
 		return
 	}
-	if results == nil {
-		http.Error(w, "data does not exist or hasn't been downloaded yet", http.StatusNotFound)
+
+	if checkNotModified(w, r, results) {
+		return
+	}
+
+	sendResponseCandles(w, r, results)
+
+}
+
+// conversionPathHeader lists the symbols of a conversion path in order, symbols whose price divides are prefixed
+// with 1/
+func conversionPathHeader(path []store.ConversionStep) string {
+	steps := make([]string, len(path))
+	for i, step := range path {
+		steps[i] = step.String()
+	}
+	return strings.Join(steps, ", ")
+}
+
+// adjustParameter reads the adjust query parameter, reporting invalid values to the client
+func adjustParameter(w http.ResponseWriter, r *http.Request) (database.Adjustment, bool) {
+	adjust, ok := database.ParseAdjustment(r.URL.Query().Get("adjust"))
+	if !ok {
+		sendInvalidParameter(w, r, "adjust")
+	}
+	return adjust, ok
+}
+
+// maxRangeCandles limits the number of candles returned by a single range request
+const maxRangeCandles = 4 * candlestick.CandleSetSize
+
+func getCandleRange(w http.ResponseWriter, r *http.Request) {
+
+	symbol := mux.Vars(r)["symbol"]
+	if s := store.AssetInfo(symbol); s == nil {
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
+		return
+	}
+
+	fromS := r.URL.Query().Get("from")
+	from, err := strconv.ParseInt(fromS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "from")
+		return
+	}
+
+	toS := r.URL.Query().Get("to")
+	to, err := strconv.ParseInt(toS, 10, 64)
+	if err != nil {
+		sendInvalidParameter(w, r, "to")
+		return
+	}
+
+	intervalS := r.URL.Query().Get("interval")
+	interval, err := strconv.ParseInt(intervalS, 10, 64)
+	if err != nil || interval <= 0 {
+		sendInvalidParameter(w, r, "interval")
+		return
+	}
+
+	if to <= from {
+		sendError(w, r, http.StatusBadRequest, codeInvalidParameter, "to must be greater than from", map[string]string{"parameter": "to"})
+		return
+	}
+
+	// limit the span and point to the next page when the window is too large
+	pageEnd := to
+	if (to-from)/interval > maxRangeCandles {
+		pageEnd = database.PeriodStart(from, interval) + maxRangeCandles*interval
+		q := r.URL.Query()
+		q.Set("from", strconv.FormatInt(pageEnd, 10))
+		w.Header().Set("Link", "<"+r.URL.Path+"?"+q.Encode()+">; rel=\"next\"")
+	}
+
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
+		return
+	}
+
+	useCache := r.URL.Query().Get("cache") != "no-cache"
+
+	results, err := database.FetchCandleRange(symbol, from, pageEnd, interval, adjust, useCache)
+	if err != nil {
+		sendFetchError(w, r, err, map[string]string{
+			"symbol":   symbol,
+			"from":     fromS,
+			"to":       toS,
+			"interval": intervalS,
+		})
 		return
 	}
 
@@ -88,39 +262,48 @@ func getTransition(w http.ResponseWriter, r *http.Request) {
 
 	symbol := mux.Vars(r)["symbol"]
 	if s := store.AssetInfo(symbol); s == nil {
-		http.Error(w, "symbol not found", http.StatusNotFound)
+		sendError(w, r, http.StatusNotFound, codeSymbolNotFound, "symbol not found", map[string]string{"symbol": symbol})
 		return
 	}
 
 	segmentS := r.URL.Query().Get("segment")
 	segment, err := strconv.ParseInt(segmentS, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid segment parameter", http.StatusBadRequest)
+		sendInvalidParameter(w, r, "segment")
 		return
 	}
 
 	intervalS := r.URL.Query().Get("interval")
 	interval, err := strconv.ParseInt(intervalS, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid interval parameter", http.StatusBadRequest)
+		sendInvalidParameter(w, r, "interval")
 		return
 	}
 
 	resolutionS := r.URL.Query().Get("resolution")
 	resolution, err := strconv.ParseInt(resolutionS, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid resolution parameter", http.StatusBadRequest)
+		sendInvalidParameter(w, r, "resolution")
 		return
 	}
 
-	results, err := database.FetchTransition(symbol, segment, interval, resolution)
+	adjust, ok := adjustParameter(w, r)
+	if !ok {
+		return
+	}
 
+	results, err := database.FetchTransition(symbol, segment, interval, resolution, adjust)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sendFetchError(w, r, err, map[string]string{
+			"symbol":     symbol,
+			"segment":    segmentS,
+			"interval":   intervalS,
+			"resolution": resolutionS,
+		})
 		return
 	}
-	if results == nil {
-		http.Error(w, "data does not exist or hasn't been downloaded yet", http.StatusNotFound)
+
+	if checkNotModified(w, r, results) {
 		return
 	}
 
@@ -136,11 +319,34 @@ func router() *mux.Router {
 
 	r := mux.NewRouter()
 
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", getHealth).Methods("GET")
+	r.HandleFunc("/readyz", getReadiness).Methods("GET")
+
+	r.HandleFunc("/admin/redownload", requireAdmin(postRedownload)).Methods("POST")
+	r.HandleFunc("/admin/jobs", requireAdmin(getJobs)).Methods("GET")
+	r.HandleFunc("/admin/jobs/{id}", requireAdmin(getJob)).Methods("GET")
+	r.HandleFunc("/admin/cache", requireAdmin(getCacheStats)).Methods("GET")
+	r.HandleFunc("/admin/cache", requireAdmin(deleteCache)).Methods("DELETE")
+	r.HandleFunc("/admin/actions/{symbol}", requireAdmin(postAction)).Methods("POST")
+	r.HandleFunc("/admin/actions/{symbol}/{id}", requireAdmin(deleteAction)).Methods("DELETE")
+
 	r.HandleFunc("/market/last-update", lastCandleUpdate).Methods("GET")
 	r.HandleFunc("/market/info", getMarketInfo).Methods("GET")
 	r.HandleFunc("/market/intervals", getIntervalList).Methods("GET")
 	r.HandleFunc("/market/symbols", getSymbols).Methods("GET")
+	r.HandleFunc("/market/stream", streamCandles).Methods("GET")
+	r.HandleFunc("/market/events", streamEvents).Methods("GET")
+	r.HandleFunc("/market/batch", postBatch).Methods("POST")
 	r.HandleFunc("/market/t/{symbol}", getTransition).Methods("GET")
+	r.HandleFunc("/market/{symbol}/bars/renko", getRenkoBars).Methods("GET")
+	r.HandleFunc("/market/{symbol}/bars/range", getRangeBars).Methods("GET")
+	r.HandleFunc("/market/{symbol}/bars/volume", getVolumeBars).Methods("GET")
+	r.HandleFunc("/market/{symbol}/actions", getActions).Methods("GET")
+	r.HandleFunc("/market/{symbol}/indicators", getIndicator).Methods("GET")
+	r.HandleFunc("/market/{symbol}/availability", getAvailability).Methods("GET")
+	r.HandleFunc("/market/{symbol}/calendar", getCalendarCandles).Methods("GET")
+	r.HandleFunc("/market/{symbol}/range", getCandleRange).Methods("GET")
 	r.HandleFunc("/market/{symbol}", getCandles).Methods("GET")
 
 	return r
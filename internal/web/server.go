@@ -14,12 +14,13 @@ import (
 func RunHttpServer() (chan interface{}, chan interface{}) {
 
 	// Middleware and routes
-	app := negroni.New(negroni.NewRecovery())
-	app.UseHandler(router())
+	routes := router()
+	app := negroni.New(negroni.NewRecovery(), &metricsMiddleware{router: routes}, &compressionMiddleware{})
+	app.UseHandler(routes)
 
 	// CORS
-	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "Cache-Control"})
-	exposedOk := handlers.ExposedHeaders([]string{"Link"})
+	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "Cache-Control", "If-None-Match"})
+	exposedOk := handlers.ExposedHeaders([]string{"Link", "ETag", "Conversion-Path"})
 	originsOk := handlers.AllowedOrigins(strings.Split(config.ServiceConfig().AllowedOrigins(), ","))
 	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS", "DELETE"})
 	handler := handlers.CORS(originsOk, headersOk, methodsOk, exposedOk)(app)
@@ -28,6 +29,7 @@ func RunHttpServer() (chan interface{}, chan interface{}) {
 		Addr:    ":" + config.ServiceConfig().Port(),
 		Handler: handler,
 	}
+	server.RegisterOnShutdown(closeEventStreams)
 
 	done := make(chan interface{})
 	stop := make(chan interface{})
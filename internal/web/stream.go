@@ -0,0 +1,362 @@
+package web
+
+import (
+	"github.com/godoji/candlestick"
+	"github.com/gorilla/websocket"
+	"kio/internal/config"
+	"kio/internal/database"
+	"kio/internal/store"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamWriteWait    = 10 * time.Second
+	streamPongWait     = 60 * time.Second
+	streamPingPeriod   = (streamPongWait * 9) / 10
+	streamSendBuffer   = 256
+	streamMaxReadBytes = 4096
+	streamPollPeriod   = 20 * time.Second
+)
+
+type streamRequest struct {
+	Action   string `json:"action"`
+	Symbol   string `json:"symbol"`
+	Interval int64  `json:"interval"`
+}
+
+type streamMessage struct {
+	Type     string              `json:"type"`
+	Symbol   string              `json:"symbol,omitempty"`
+	Interval int64               `json:"interval,omitempty"`
+	Candle   *candlestick.Candle `json:"candle,omitempty"`
+	Code     string              `json:"code,omitempty"`
+	Message  string              `json:"message,omitempty"`
+}
+
+type streamSubscription struct {
+	symbol   string
+	interval int64
+}
+
+type streamClient struct {
+	conn *websocket.Conn
+	send chan *streamMessage
+	// subscriptions map to the open time of the last period sent to the client
+	subscriptions map[streamSubscription]int64
+	closed        bool
+	lock          sync.Mutex
+}
+
+var streamClients = make(map[*streamClient]bool)
+var streamClientsLock = sync.Mutex{}
+var streamListenerOnce = sync.Once{}
+
+// streamPoller refreshes the real-time block of a symbol for as long as clients are subscribed to it
+type streamPoller struct {
+	subscribers int
+	stop        chan struct{}
+}
+
+var streamPollers = make(map[string]*streamPoller)
+var streamPollersLock = sync.Mutex{}
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkStreamOrigin,
+}
+
+func checkStreamOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(config.ServiceConfig().AllowedOrigins(), ",") {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *streamClient) close() {
+	c.lock.Lock()
+	c.closeLocked()
+	subs := c.subscriptions
+	c.subscriptions = make(map[streamSubscription]int64)
+	c.lock.Unlock()
+	streamClientsLock.Lock()
+	delete(streamClients, c)
+	streamClientsLock.Unlock()
+	for sub := range subs {
+		releasePoller(sub.symbol)
+	}
+}
+
+func (c *streamClient) closeLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// push queues a message without blocking, slow clients are disconnected
+func (c *streamClient) push(msg *streamMessage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		log.Println("stream client is too slow, disconnecting")
+		c.closeLocked()
+	}
+}
+
+// update sends the current candle of a subscription and the closed candle when a period completes
+func (c *streamClient) update(sub streamSubscription, data *candlestick.CandleSet) {
+
+	latest, ok := database.LatestCandleTime(data)
+	if !ok || sub.interval%data.Interval() != 0 {
+		return
+	}
+	period := database.PeriodStart(latest, sub.interval)
+
+	c.lock.Lock()
+	lastPeriod, subscribed := c.subscriptions[sub]
+	if subscribed {
+		c.subscriptions[sub] = period
+	}
+	c.lock.Unlock()
+	if !subscribed {
+		return
+	}
+
+	// send the completed candle of the previous period
+	if lastPeriod != 0 && lastPeriod < period {
+		closed, err := database.AggregatePeriod(data, lastPeriod, sub.interval)
+		if err != nil {
+			log.Println(err)
+		} else {
+			c.push(&streamMessage{Type: "closed", Symbol: sub.symbol, Interval: sub.interval, Candle: &closed})
+		}
+	}
+
+	current, err := database.AggregatePeriod(data, period, sub.interval)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	c.push(&streamMessage{Type: "candle", Symbol: sub.symbol, Interval: sub.interval, Candle: &current})
+}
+
+func (c *streamClient) subscribe(req *streamRequest) {
+
+	info := store.AssetInfo(req.Symbol)
+	if info == nil {
+		c.push(&streamMessage{Type: "error", Symbol: req.Symbol, Interval: req.Interval, Code: codeSymbolNotFound, Message: "symbol not found"})
+		return
+	}
+
+	// synthetic symbols have no real-time block of their own, their legs have to be subscribed instead
+	if _, ok := store.ParseSynthetic(req.Symbol); ok {
+		c.push(&streamMessage{Type: "error", Symbol: req.Symbol, Interval: req.Interval, Code: codeSyntheticSymbol, Message: "synthetic symbols can not be streamed, subscribe to their legs instead"})
+		return
+	}
+
+	// only intervals which can be derived from the exchange resolution can be streamed
+	exchangeInfo := store.ExchangeInfo(info.Identifier.Exchange)
+	isValid := false
+	if exchangeInfo != nil && req.Interval > 0 {
+		for _, resolution := range exchangeInfo.Resolution {
+			if req.Interval%resolution == 0 {
+				isValid = true
+				break
+			}
+		}
+	}
+	if !isValid {
+		c.push(&streamMessage{Type: "error", Symbol: req.Symbol, Interval: req.Interval, Code: codeInvalidInterval, Message: "invalid interval"})
+		return
+	}
+
+	sub := streamSubscription{symbol: req.Symbol, interval: req.Interval}
+	c.lock.Lock()
+	_, exists := c.subscriptions[sub]
+	c.subscriptions[sub] = 0
+	c.lock.Unlock()
+	if !exists {
+		acquirePoller(req.Symbol)
+	}
+
+	// send the current state right away if there is real-time data, otherwise the poller delivers it shortly
+	if data := database.LatestRealTimeBlock(req.Symbol); data != nil {
+		c.update(sub, data)
+	}
+}
+
+func (c *streamClient) unsubscribe(req *streamRequest) {
+	sub := streamSubscription{symbol: req.Symbol, interval: req.Interval}
+	c.lock.Lock()
+	_, exists := c.subscriptions[sub]
+	delete(c.subscriptions, sub)
+	c.lock.Unlock()
+	if exists {
+		releasePoller(req.Symbol)
+	}
+}
+
+func (c *streamClient) readPump() {
+	defer func() {
+		c.close()
+		_ = c.conn.Close()
+	}()
+	c.conn.SetReadLimit(streamMaxReadBytes)
+	_ = c.conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+	for {
+		req := new(streamRequest)
+		if err := c.conn.ReadJSON(req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Println(err)
+			}
+			return
+		}
+		switch req.Action {
+		case "subscribe":
+			c.subscribe(req)
+		case "unsubscribe":
+			c.unsubscribe(req)
+		default:
+			c.push(&streamMessage{Type: "error", Code: codeInvalidRequest, Message: "unknown action " + req.Action})
+		}
+	}
+}
+
+func (c *streamClient) writePump() {
+	ticker := time.NewTicker(streamPingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// broadcastRealTimeUpdate forwards a changed real-time block to all clients subscribed to its symbol
+func broadcastRealTimeUpdate(data *candlestick.CandleSet) {
+	streamClientsLock.Lock()
+	clients := make([]*streamClient, 0, len(streamClients))
+	for c := range streamClients {
+		clients = append(clients, c)
+	}
+	streamClientsLock.Unlock()
+
+	for _, c := range clients {
+		c.lock.Lock()
+		subs := make([]streamSubscription, 0)
+		for sub := range c.subscriptions {
+			if sub.symbol == data.Symbol() {
+				subs = append(subs, sub)
+			}
+		}
+		c.lock.Unlock()
+		for _, sub := range subs {
+			c.update(sub, data)
+		}
+	}
+}
+
+// acquirePoller registers a subscription to a symbol, the first one starts polling the symbol
+func acquirePoller(symbol string) {
+	streamPollersLock.Lock()
+	defer streamPollersLock.Unlock()
+	p, ok := streamPollers[symbol]
+	if !ok {
+		p = &streamPoller{stop: make(chan struct{})}
+		streamPollers[symbol] = p
+		go pollSymbol(symbol, p.stop)
+	}
+	p.subscribers++
+}
+
+// releasePoller removes a subscription to a symbol, polling stops with the last one
+func releasePoller(symbol string) {
+	streamPollersLock.Lock()
+	defer streamPollersLock.Unlock()
+	p, ok := streamPollers[symbol]
+	if !ok {
+		return
+	}
+	p.subscribers--
+	if p.subscribers <= 0 {
+		close(p.stop)
+		delete(streamPollers, symbol)
+	}
+}
+
+// pollSymbol keeps the real-time block of a symbol up to date until stopped, every refresh is broadcast to the
+// subscribers through the real-time listener
+func pollSymbol(symbol string, stop chan struct{}) {
+	ticker := time.NewTicker(streamPollPeriod)
+	defer ticker.Stop()
+	for {
+		if err := database.RefreshRealTimeBlock(symbol); err != nil {
+			log.Printf("could not refresh real-time block of %s: %v\n", symbol, err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func streamCandles(w http.ResponseWriter, r *http.Request) {
+
+	streamListenerOnce.Do(func() {
+		database.OnRealTimeUpdate(broadcastRealTimeUpdate)
+	})
+
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	client := &streamClient{
+		conn:          conn,
+		send:          make(chan *streamMessage, streamSendBuffer),
+		subscriptions: make(map[streamSubscription]int64),
+	}
+	streamClientsLock.Lock()
+	streamClients[client] = true
+	streamClientsLock.Unlock()
+
+	go client.writePump()
+	go client.readPump()
+}
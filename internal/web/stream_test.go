@@ -0,0 +1,117 @@
+package web
+
+import (
+	"github.com/godoji/candlestick"
+	"testing"
+)
+
+func realTimeSet(symbol string, block int64, closes ...float64) *candlestick.CandleSet {
+	data := &candlestick.CandleSet{
+		Candles: make([]candlestick.Candle, candlestick.CandleSetSize),
+		Meta:    candlestick.DataSetMeta{Block: block, Symbol: symbol, Interval: 60},
+	}
+	for i := range data.Candles {
+		data.Candles[i] = candlestick.Candle{Time: data.TimeStampAtIndex(int64(i)), Missing: true}
+	}
+	for i, v := range closes {
+		data.Candles[i] = candlestick.Candle{Open: v, High: v, Low: v, Close: v, Time: data.TimeStampAtIndex(int64(i))}
+	}
+	return data
+}
+
+func TestRealTimeUpdateReachesSubscriber(t *testing.T) {
+
+	const symbol = "TEST:SPOT:STREAM"
+	sub := streamSubscription{symbol: symbol, interval: 60}
+	client := &streamClient{
+		send:          make(chan *streamMessage, streamSendBuffer),
+		subscriptions: map[streamSubscription]int64{sub: 0},
+	}
+	streamClientsLock.Lock()
+	streamClients[client] = true
+	streamClientsLock.Unlock()
+	defer func() {
+		streamClientsLock.Lock()
+		delete(streamClients, client)
+		streamClientsLock.Unlock()
+	}()
+
+	// the first update carries the in-progress candle
+	broadcastRealTimeUpdate(realTimeSet(symbol, 6000, 1))
+	if msg := <-client.send; msg.Type != "candle" || msg.Candle.Close != 1 {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// a new period closes the previous candle before sending the next one
+	broadcastRealTimeUpdate(realTimeSet(symbol, 6000, 1, 2))
+	if msg := <-client.send; msg.Type != "closed" || msg.Candle.Close != 1 {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if msg := <-client.send; msg.Type != "candle" || msg.Candle.Close != 2 {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// other symbols are not forwarded
+	broadcastRealTimeUpdate(realTimeSet("TEST:SPOT:OTHER", 6000, 1))
+	if len(client.send) != 0 {
+		t.Errorf("update of another symbol was forwarded")
+	}
+}
+
+func TestPollerSharedBySubscribers(t *testing.T) {
+
+	// synthetic symbols fail their refresh before reaching the bridge
+	const symbol = "SYN:RATIO:A:SPOT:X:B:SPOT:Y"
+	pollers := func() (*streamPoller, bool) {
+		streamPollersLock.Lock()
+		defer streamPollersLock.Unlock()
+		p, ok := streamPollers[symbol]
+		return p, ok
+	}
+
+	acquirePoller(symbol)
+	first, ok := pollers()
+	if !ok {
+		t.Fatal("no poller started")
+	}
+	acquirePoller(symbol)
+	if p, _ := pollers(); p != first || p.subscribers != 2 {
+		t.Fatalf("second subscriber did not share the poller")
+	}
+
+	releasePoller(symbol)
+	if _, ok = pollers(); !ok {
+		t.Fatal("poller stopped while subscribed")
+	}
+	releasePoller(symbol)
+	if _, ok = pollers(); ok {
+		t.Fatal("poller kept after the last subscriber left")
+	}
+	select {
+	case <-first.stop:
+	default:
+		t.Error("poller was not stopped")
+	}
+}
+
+func TestClosingClientReleasesPollers(t *testing.T) {
+
+	const symbol = "SYN:SPREAD:A:SPOT:X:B:SPOT:Y"
+	client := &streamClient{
+		send: make(chan *streamMessage, streamSendBuffer),
+		subscriptions: map[streamSubscription]int64{
+			{symbol: symbol, interval: 60}:  0,
+			{symbol: symbol, interval: 300}: 0,
+		},
+	}
+	acquirePoller(symbol)
+	acquirePoller(symbol)
+
+	client.close()
+	streamPollersLock.Lock()
+	_, ok := streamPollers[symbol]
+	streamPollersLock.Unlock()
+	if ok {
+		t.Error("poller kept after its client closed")
+	}
+}